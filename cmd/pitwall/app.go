@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+	"github.com/bhopalg/pitwall/internal/cache"
+	"github.com/bhopalg/pitwall/internal/config"
+	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/openf1/replay"
+	"github.com/bhopalg/pitwall/internal/output"
+	"github.com/bhopalg/pitwall/internal/refresh"
+	"github.com/bhopalg/pitwall/internal/services/getsession"
+	"github.com/bhopalg/pitwall/internal/services/latest"
+	"github.com/bhopalg/pitwall/internal/services/weekend"
+	"github.com/bhopalg/pitwall/utils"
+)
+
+// defaultTimeZone is the zone times are shown in unless --tz or the config
+// picks another.
+const defaultTimeZone = "Europe/London"
+
+// globalOptions are the flags given before the command name.
+type globalOptions struct {
+	config               string
+	profile              string
+	offlineFixtures      string
+	recordFixtures       string
+	cacheDir             string
+	cacheTTL             string
+	cacheMaxSize         string
+	cacheCompressAbove   string
+	cacheMaxEntries      int
+	cacheBackend         string
+	offline              bool
+	staleWhileRevalidate bool
+	output               string
+	format               string
+	tz                   string
+	trackTime            bool
+}
+
+// app is the state shared by commands: the global flags, the config
+// profile, and once setup has run, the cache, API client and service
+// options.
+type app struct {
+	env    env
+	now    time.Time
+	flags  globalOptions
+	format output.Format
+	// template is set by --format and takes precedence over format.
+	template *template.Template
+	// loc is the zone times are shown in, from --tz.
+	loc *time.Location
+
+	config     *config.Config
+	configPath string
+	profile    config.Profile
+	// profileErr is reported by setup rather than straight away, so config
+	// commands can create a profile that does not exist yet.
+	profileErr error
+	// envErrs holds invalid environment settings by flag name; see envBool and envInt.
+	envErrs map[string]error
+
+	ctx            context.Context
+	cache          *cache.Layered
+	client         *openf1.Client
+	ttl            cache.TTLPolicy
+	getSessionOpts []getsession.Option
+	weekendOpts    []weekend.Option
+	latestOpts     []latest.Option
+
+	cleanup []func()
+}
+
+func (a *app) globalFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("pitwall", flag.ContinueOnError)
+	fs.StringVar(&a.flags.config, "config", os.Getenv("PITWALL_CONFIG"), "config file (default: the user config directory, e.g. ~/.config/pitwall/config.yaml)")
+	fs.StringVar(&a.flags.profile, "profile", os.Getenv("PITWALL_PROFILE"), "config profile to use")
+	fs.StringVar(&a.flags.offlineFixtures, "offline-fixtures", "", "replay OpenF1 responses from fixtures in this directory instead of the network")
+	fs.StringVar(&a.flags.recordFixtures, "record-fixtures", "", "record OpenF1 responses as fixtures into this directory")
+	fs.StringVar(&a.flags.cacheDir, "cache-dir", os.Getenv("PITWALL_CACHE_DIR"), "directory to keep the cache in (default: the user cache directory, e.g. ~/.cache/pitwall)")
+	fs.StringVar(&a.flags.cacheTTL, "cache-ttl", os.Getenv("PITWALL_CACHE_TTL"), "cache TTL overrides, e.g. live=30s,future=12h,finished=8760h,latest=1h")
+	fs.StringVar(&a.flags.cacheMaxSize, "cache-max-size", os.Getenv("PITWALL_CACHE_MAX_SIZE"), "evict least recently used cache entries above this size, e.g. 500MB")
+	fs.StringVar(&a.flags.cacheCompressAbove, "cache-compress-above", os.Getenv("PITWALL_CACHE_COMPRESS_ABOVE"), "gzip cache entries larger than this, e.g. 4KB, or off")
+	fs.IntVar(&a.flags.cacheMaxEntries, "cache-max-entries", a.envInt("cache-max-entries", "PITWALL_CACHE_MAX_ENTRIES"), "evict least recently used cache entries above this count")
+	fs.StringVar(&a.flags.cacheBackend, "cache-backend", os.Getenv("PITWALL_CACHE_BACKEND"), "cache storage: file (one JSON file per entry) or bolt (a single database file)")
+	fs.BoolVar(&a.flags.offline, "offline", a.envBool("offline", "PITWALL_OFFLINE"), "never use the network; answer from the cache only")
+	fs.StringVar(&a.flags.output, "output", os.Getenv("PITWALL_OUTPUT"), "output format: table, json, yaml, csv or ndjson")
+	fs.StringVar(&a.flags.format, "format", os.Getenv("PITWALL_FORMAT"), "Go template for each result, e.g. '{{.SessionName}} {{.DateStart | relative}}'")
+	fs.StringVar(&a.flags.tz, "tz", os.Getenv("PITWALL_TZ"), "time zone to show times in, e.g. America/New_York (default: "+defaultTimeZone+")")
+	fs.BoolVar(&a.flags.trackTime, "track-time", a.envBool("track-time", "PITWALL_TRACK_TIME"), "show session times in the circuit's local time")
+	fs.BoolVar(&a.flags.staleWhileRevalidate, "stale-while-revalidate", a.envBool("stale-while-revalidate", "PITWALL_STALE_WHILE_REVALIDATE"), "return stale cached data immediately and refresh it in the background")
+	return fs
+}
+
+// loadConfig reads the config file and fills in the global flags that were
+// not given on the command line or in the environment.
+func (a *app) loadConfig() error {
+	a.configPath = a.flags.config
+	if a.configPath == "" {
+		a.configPath = a.env.configPath
+	}
+	if a.configPath == "" {
+		path, err := config.DefaultPath()
+		if err != nil {
+			return fmt.Errorf("finding config file: %w", err)
+		}
+		a.configPath = path
+	}
+
+	cfg, err := config.Load(a.configPath)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	a.config = cfg
+
+	a.profile, a.profileErr = cfg.Resolve(a.flags.profile)
+
+	// Flags and environment variables win over the config file.
+	setDefault(&a.flags.cacheDir, a.profile.Cache.Dir)
+	setDefault(&a.flags.cacheTTL, a.profile.Cache.TTL)
+	setDefault(&a.flags.cacheMaxSize, a.profile.Cache.MaxSize)
+	setDefault(&a.flags.cacheCompressAbove, a.profile.Cache.CompressAbove)
+	setDefault(&a.flags.cacheBackend, a.profile.Cache.Backend)
+	setDefault(&a.flags.output, a.profile.Output)
+	setDefault(&a.flags.tz, a.profile.Timezone)
+	if a.flags.cacheMaxEntries == 0 {
+		a.flags.cacheMaxEntries = a.profile.Cache.MaxEntries
+	}
+	if a.profile.APIBaseURL != "" {
+		a.env.baseURL = a.profile.APIBaseURL
+	}
+
+	a.format, err = output.ParseFormat(a.flags.output)
+	if err != nil {
+		return err
+	}
+
+	a.loc = a.env.loc
+	if a.loc == nil {
+		// Without tzdata UK time cannot be loaded, so fall back to UTC.
+		a.loc, err = time.LoadLocation(defaultTimeZone)
+		if err != nil {
+			a.loc = time.UTC
+		}
+	}
+	if a.flags.tz != "" {
+		a.loc, err = time.LoadLocation(a.flags.tz)
+		if err != nil {
+			return fmt.Errorf("--tz: %w", err)
+		}
+	}
+
+	if a.flags.format != "" {
+		a.template, err = output.NewTemplate(a.flags.format, a.now, a.loc)
+		if err != nil {
+			return fmt.Errorf("--format: %w", err)
+		}
+	}
+	return nil
+}
+
+// render writes result with the --format template or in the --output
+// format, and returns code. The table format is each command's own text,
+// printed by table.
+func (a *app) render(result output.Lister, code int, table func()) int {
+	if a.template != nil {
+		if err := output.WriteTemplate(os.Stdout, a.template, result); err != nil {
+			fmt.Fprintln(os.Stderr, "error: --format:", err)
+			return exitError
+		}
+		return code
+	}
+
+	if a.format == output.FormatTable {
+		table()
+		return code
+	}
+
+	if err := output.Write(os.Stdout, a.format, result); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+	return code
+}
+
+// zone returns the zone to show a session's times in.
+func (a *app) zone(s *domain.Session) *time.Location {
+	return utils.SessionZone(s, a.loc, a.flags.trackTime)
+}
+
+// setup opens the cache and creates the API client and service options.
+// Commands marked local run without it.
+func (a *app) setup() error {
+	if a.profileErr != nil {
+		return a.profileErr
+	}
+
+	var (
+		maxBytes int64
+		err      error
+	)
+	if a.flags.cacheMaxSize != "" {
+		maxBytes, err = cache.ParseSize(a.flags.cacheMaxSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	var compressAbove int
+	switch a.flags.cacheCompressAbove {
+	case "":
+	case "off":
+		compressAbove = -1
+	default:
+		threshold, err := cache.ParseSize(a.flags.cacheCompressAbove)
+		if err != nil {
+			return err
+		}
+		compressAbove = int(threshold)
+	}
+
+	dir, err := resolveCacheDir(a.flags.cacheDir, a.env)
+	if err != nil {
+		return err
+	}
+
+	registerCodecs()
+
+	var store cache.Cache
+	switch a.flags.cacheBackend {
+	case "", "file":
+		store = &cache.FileCache{Dir: dir, MaxBytes: maxBytes, MaxEntries: a.flags.cacheMaxEntries, CompressAbove: compressAbove}
+	case "bolt":
+		boltCache := cache.NewBoltCache(filepath.Join(dir, cache.BoltFile))
+		boltCache.MaxBytes = maxBytes
+		boltCache.MaxEntries = a.flags.cacheMaxEntries
+		boltCache.CompressAbove = compressAbove
+		store = boltCache
+	default:
+		return fmt.Errorf("unknown cache backend %q (want file or bolt)", a.flags.cacheBackend)
+	}
+	a.cache = cache.NewLayered(store, 256)
+
+	a.ttl, err = cache.ParseTTLPolicy(a.flags.cacheTTL, cache.DefaultTTLPolicy())
+	if err != nil {
+		return err
+	}
+
+	a.getSessionOpts = []getsession.Option{getsession.WithTTLPolicy(a.ttl), getsession.WithClock(a.env.now)}
+	a.weekendOpts = []weekend.Option{weekend.WithTTLPolicy(a.ttl), weekend.WithClock(a.env.now), weekend.WithZone(a.zone)}
+	a.latestOpts = []latest.Option{latest.WithTTLPolicy(a.ttl), latest.WithClock(a.env.now)}
+	if a.flags.offline {
+		a.getSessionOpts = append(a.getSessionOpts, getsession.WithOffline())
+		a.weekendOpts = append(a.weekendOpts, weekend.WithOffline())
+		a.latestOpts = append(a.latestOpts, latest.WithOffline())
+	}
+	if a.flags.staleWhileRevalidate && !a.flags.offline {
+		refresher := refresh.New(4, 10*time.Second)
+		a.getSessionOpts = append(a.getSessionOpts, getsession.WithRefresher(refresher))
+		a.weekendOpts = append(a.weekendOpts, weekend.WithRefresher(refresher))
+		a.latestOpts = append(a.latestOpts, latest.WithRefresher(refresher))
+
+		// Let background refreshes land in the cache before the process exits.
+		a.cleanup = append(a.cleanup, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := refresher.Flush(ctx); err != nil {
+				fmt.Fprintln(os.Stderr, "refresh:", err)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	a.ctx = ctx
+	a.cleanup = append(a.cleanup, cancel)
+
+	clientOpts := []openf1.Option{openf1.WithBaseURL(a.env.baseURL)}
+	switch {
+	case a.flags.offlineFixtures != "":
+		clientOpts = append(clientOpts, openf1.WithTransport(replay.New(a.flags.offlineFixtures, replay.Replay)))
+	case a.flags.recordFixtures != "":
+		clientOpts = append(clientOpts, openf1.WithTransport(replay.New(a.flags.recordFixtures, replay.Record)))
+	}
+	if a.flags.offline {
+		clientOpts = append(clientOpts, openf1.WithOffline())
+	}
+	a.client = openf1.New(clientOpts...)
+
+	return nil
+}
+
+// close undoes setup, last step first.
+func (a *app) close() {
+	for i := len(a.cleanup) - 1; i >= 0; i-- {
+		a.cleanup[i]()
+	}
+}
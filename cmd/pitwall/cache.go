@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bhopalg/pitwall/internal/cache"
+	"github.com/bhopalg/pitwall/internal/output"
+	"github.com/bhopalg/pitwall/internal/services/warm"
+)
+
+func cacheCommand() *command {
+	return &command{
+		name:    "cache",
+		args:    "<command> [flags]",
+		summary: "Inspect and manage the local cache",
+		subcommands: []*command{
+			cacheInfoCommand(),
+			cacheClearCommand(),
+			cacheVerifyCommand(),
+			cachePruneCommand(),
+			cacheRmCommand(),
+			cacheExportCommand(),
+			cacheImportCommand(),
+			cacheWarmCommand(),
+		},
+	}
+}
+
+func cacheInfoCommand() *command {
+	return &command{
+		name:    "info",
+		summary: "List cached entries",
+		run: func(a *app, args []string) int {
+			entries, path, err := a.cache.Info()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading cache: %v\n", err)
+				return exitError
+			}
+
+			result := output.CacheInfoResult{Location: path, Entries: []output.CacheEntry{}}
+			for _, e := range entries {
+				result.Entries = append(result.Entries, output.NewCacheEntry(e))
+			}
+
+			return a.render(result, exitOK, func() {
+				var healthy, corrupt []cache.InfoEntry
+				for _, e := range entries {
+					if e.Corrupt {
+						corrupt = append(corrupt, e)
+					} else {
+						healthy = append(healthy, e)
+					}
+				}
+				entries = healthy
+
+				fmt.Printf("Cache Location: %s\n", path)
+				fmt.Printf("Total Entries:  %d\n\n", len(entries))
+
+				if len(entries) > 0 {
+					fmt.Printf("%-30s %-20s %-10s %-12s %-12s\n", "KEY", "CREATED AT", "STALE", "SIZE", "RAW SIZE")
+					for _, e := range entries {
+						staleStr := "no"
+						if e.IsStale {
+							staleStr = "YES"
+						}
+						fmt.Printf("%-30s %-20s %-10s %-12s %-12s\n",
+							e.Key,
+							e.CreatedAt.Format("02 Jan 15:04"),
+							staleStr,
+							fmt.Sprintf("%d B", e.Size),
+							fmt.Sprintf("%d B", e.RawSize),
+						)
+					}
+				}
+
+				if len(corrupt) > 0 {
+					fmt.Printf("\nQuarantined (corrupt) entries: %d\n", len(corrupt))
+					for _, e := range corrupt {
+						fmt.Printf("%-30s %s\n", e.Key, e.File)
+					}
+				}
+			})
+		},
+	}
+}
+
+func cacheClearCommand() *command {
+	return &command{
+		name:    "clear",
+		summary: "Remove every cached entry",
+		run: func(a *app, args []string) int {
+			count, err := a.cache.Clear()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error clearing cache: %v\n", err)
+				return exitError
+			}
+			if count == 0 {
+				fmt.Println("Nothing to clear.")
+			} else {
+				fmt.Printf("Successfully removed %d cache entries.\n", count)
+			}
+			return exitOK
+		},
+	}
+}
+
+func cacheVerifyCommand() *command {
+	return &command{
+		name:    "verify",
+		summary: "Check the cache for corrupt entries",
+		help:    "Report unreadable, corrupt, quarantined and unindexed entries. Exits 1 if any\nare found.",
+		run: func(a *app, args []string) int {
+			problems, err := a.cache.Verify()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error verifying cache: %v\n", err)
+				return exitError
+			}
+			if len(problems) == 0 {
+				fmt.Println("Cache OK.")
+				return exitOK
+			}
+
+			fmt.Printf("Found %d problem(s):\n", len(problems))
+			for _, p := range problems {
+				fmt.Printf("  %v\n", p)
+			}
+			return exitNoResult
+		},
+	}
+}
+
+func cachePruneCommand() *command {
+	var (
+		stale     bool
+		olderThan string
+		prefix    string
+	)
+
+	return &command{
+		name:    "prune",
+		args:    "[flags]",
+		summary: "Remove stale or old entries",
+		help:    "Remove entries matching every given filter, then evict down to the cache\nsize limits.",
+		flags: func(fs *flag.FlagSet, a *app) {
+			fs.BoolVar(&stale, "stale", false, "remove expired entries")
+			fs.StringVar(&olderThan, "older-than", "", "remove entries created longer ago than this, e.g. 30d")
+			fs.StringVar(&prefix, "prefix", "", "only prune keys starting with this, e.g. weekend:")
+		},
+		run: func(a *app, args []string) int {
+			filter := cache.PruneFilter{Stale: stale, Prefix: prefix}
+			if olderThan != "" {
+				d, err := cache.ParseDuration(olderThan)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "error:", err)
+					return exitError
+				}
+				filter.OlderThan = d
+			}
+
+			removed, err := a.cache.Prune(filter)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error pruning cache: %v\n", err)
+				return exitError
+			}
+			if len(removed) == 0 {
+				fmt.Println("Nothing to prune.")
+				return exitOK
+			}
+			for _, key := range removed {
+				fmt.Printf("  %s\n", key)
+			}
+			fmt.Printf("Pruned %d cache entries.\n", len(removed))
+			return exitOK
+		},
+	}
+}
+
+func cacheRmCommand() *command {
+	return &command{
+		name:    "rm",
+		args:    "<key>...",
+		summary: "Remove entries by key",
+		help:    "Remove the given cache keys, as listed by 'pitwall cache info'. Exits 1 if any\nkey was not cached.",
+		complete: func(a *app, args []string) []string {
+			return cachedKeys(a)
+		},
+		run: func(a *app, args []string) int {
+			if len(args) < 1 {
+				fmt.Fprintln(os.Stderr, "usage: pitwall cache rm <key>...")
+				return exitError
+			}
+
+			missing := 0
+			for _, key := range args {
+				removed, err := a.cache.Remove(key)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", key, err)
+					return exitError
+				}
+				if !removed {
+					fmt.Printf("No cache entry for %s.\n", key)
+					missing++
+					continue
+				}
+				fmt.Printf("Removed %s.\n", key)
+			}
+			if missing > 0 {
+				return exitNoResult
+			}
+			return exitOK
+		},
+	}
+}
+
+func cacheExportCommand() *command {
+	var (
+		year, prefix, out string
+		force             bool
+	)
+
+	return &command{
+		name:    "export",
+		args:    "[flags]",
+		summary: "Write entries to a portable bundle",
+		flags: func(fs *flag.FlagSet, a *app) {
+			fs.StringVar(&year, "year", "", "only export entries for this season")
+			fs.StringVar(&prefix, "prefix", "", "only export keys starting with this, e.g. weekend:")
+			fs.StringVar(&out, "o", "pitwall-cache.tar.gz", "bundle file to write")
+			fs.BoolVar(&force, "force", false, "overwrite the bundle file if it exists")
+		},
+		run: func(a *app, args []string) int {
+			records, err := a.cache.Export(func(key string) bool {
+				return strings.HasPrefix(key, prefix) && (year == "" || slices.Contains(strings.Split(key, ":"), year))
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error exporting cache: %v\n", err)
+				return exitError
+			}
+			if len(records) == 0 {
+				fmt.Println("Nothing to export.")
+				return exitNoResult
+			}
+
+			flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			if force {
+				flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			f, err := os.OpenFile(out, flags, 0644)
+			if errors.Is(err, os.ErrExist) {
+				fmt.Fprintf(os.Stderr, "error: %s already exists; pass --force to overwrite it\n", out)
+				return exitError
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return exitError
+			}
+			if err := cache.WriteBundle(f, records); err != nil {
+				f.Close()
+				fmt.Fprintf(os.Stderr, "Error writing bundle: %v\n", err)
+				return exitError
+			}
+			if err := f.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return exitError
+			}
+			fmt.Printf("Exported %d cache entries to %s.\n", len(records), out)
+			return exitOK
+		},
+	}
+}
+
+func cacheImportCommand() *command {
+	return &command{
+		name:    "import",
+		args:    "<bundle.tar.gz>",
+		summary: "Load entries from a bundle",
+		help:    "Load a bundle written by 'pitwall cache export'. Entries already cached more\nrecently are kept.",
+		run: func(a *app, args []string) int {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "usage: pitwall cache import <bundle.tar.gz>")
+				return exitError
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return exitError
+			}
+			records, _, err := cache.ReadBundle(f)
+			f.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading bundle: %v\n", err)
+				return exitError
+			}
+
+			n, err := a.cache.Import(records)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error importing cache: %v\n", err)
+				return exitError
+			}
+			fmt.Printf("Imported %d of %d cache entries", n, len(records))
+			if skipped := len(records) - n; skipped > 0 {
+				fmt.Printf(" (%d newer entries kept)", skipped)
+			}
+			fmt.Println(".")
+			return exitOK
+		},
+	}
+}
+
+func cacheWarmCommand() *command {
+	var (
+		year    string
+		include string
+		workers int
+	)
+
+	return &command{
+		name:    "warm",
+		args:    "[flags]",
+		summary: "Prefetch a whole season",
+		help: "Cache every weekend of a season, and optionally each session's laps, stints\n" +
+			"and results. Entries already cached and fresh are skipped, so an interrupted\n" +
+			"warm resumes when run again. Exits 1 if any entry failed.",
+		flags: func(fs *flag.FlagSet, a *app) {
+			fs.StringVar(&year, "year", strconv.Itoa(a.now.Year()), "season to warm")
+			fs.StringVar(&include, "include", "", "extra per-session data to cache: laps,stints,results")
+			fs.IntVar(&workers, "workers", 4, "number of concurrent requests")
+		},
+		run: func(a *app, args []string) int {
+			var datasets []string
+			if include != "" {
+				datasets = strings.Split(include, ",")
+			}
+
+			// Warming a season takes far longer than a single lookup, so it
+			// runs until done or interrupted instead of under a.ctx's timeout.
+			warmCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			service := warm.New(a.client, a.cache,
+				warm.WithTTLPolicy(a.ttl),
+				warm.WithClock(a.env.now),
+				warm.WithWorkers(workers),
+				warm.WithProgress(func(p warm.Progress) {
+					if p.Err != nil {
+						fmt.Printf("[%d/%d] %s %s: %v\n", p.Done, p.Total, p.Key, p.Status, p.Err)
+						return
+					}
+					fmt.Printf("[%d/%d] %s %s\n", p.Done, p.Total, p.Key, p.Status)
+				}),
+			)
+			res, err := service.Warm(warmCtx, year, datasets)
+			fmt.Printf("Fetched %d, skipped %d already cached, %d failed.\n", res.Fetched, res.Skipped, res.Failed)
+			if errors.Is(err, context.Canceled) {
+				fmt.Fprintln(os.Stderr, "Interrupted; run the same command again to resume.")
+				return exitError
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return exitError
+			}
+			if res.Failed > 0 {
+				return exitNoResult
+			}
+			return exitOK
+		},
+	}
+}
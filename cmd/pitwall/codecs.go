@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/bhopalg/pitwall/domain"
+	"github.com/bhopalg/pitwall/internal/cache"
+	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/services/warm"
+	"github.com/bhopalg/pitwall/utils"
+)
+
+const (
+	sessionsType    = "sessions"
+	sessionsVersion = 2
+)
+
+// registerCodecs tells the cache what each key prefix holds. It runs once
+// per process, however many times setup does.
+var registerCodecs = sync.OnceFunc(func() {
+	// getsession, weekend and latest all cache []domain.Session.
+	for _, prefix := range []string{"getsession:", "weekend:", "latest"} {
+		cache.RegisterCodec(prefix, sessionsCodec())
+	}
+	for prefix, c := range warm.Codecs() {
+		cache.RegisterCodec(prefix, c)
+	}
+})
+
+// sessionsCodec is the cache codec for entries holding []domain.Session.
+// It migrates untagged entries from before versioning, which held either
+// []domain.Session or a single raw openf1.Session, and v1 entries, which
+// lack meeting names and GMT offsets. Migrated entries are marked stale so
+// the missing fields are refetched.
+func sessionsCodec() cache.Codec {
+	return cache.Codec{
+		Type:    sessionsType,
+		Version: sessionsVersion,
+		New:     func() any { return new([]domain.Session) },
+		Migrate: migrateSessions,
+	}
+}
+
+func migrateSessions(entryType string, version int, data json.RawMessage) (any, error) {
+	switch {
+	case entryType == sessionsType && version == 1:
+	case entryType == "" && version == 0:
+	default:
+		return nil, fmt.Errorf("no migration from %s v%d", entryType, version)
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var sessions []domain.Session
+		if err := json.Unmarshal(data, &sessions); err != nil {
+			return nil, err
+		}
+		return cache.MarkStale(sessions), nil
+	}
+	if entryType != "" {
+		return nil, fmt.Errorf("%s v%d entry does not hold a list", entryType, version)
+	}
+
+	var apiSession openf1.Session
+	if err := json.Unmarshal(data, &apiSession); err != nil {
+		return nil, err
+	}
+	s, err := utils.MapToDomain(&apiSession)
+	if err != nil {
+		return nil, err
+	}
+	return cache.MarkStale([]domain.Session{*s}), nil
+}
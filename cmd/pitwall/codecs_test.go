@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bhopalg/pitwall/domain"
+	"github.com/bhopalg/pitwall/internal/cache"
+)
+
+func TestSessionsCodecMigrate(t *testing.T) {
+	registerCodecs()
+	fc := &cache.FileCache{Dir: t.TempDir()}
+
+	testcases := []struct {
+		name          string
+		entryType     string
+		version       int
+		data          string
+		expectedError bool
+		expectedName  string
+	}{
+		{
+			name:         "Legacy raw openf1 session",
+			data:         `{"session_key":9141,"session_name":"Race","date_start":"2023-07-30T13:00:00+00:00","date_end":"2023-07-30T15:00:00+00:00"}`,
+			expectedName: "Race",
+		},
+		{
+			name:         "Legacy domain sessions",
+			data:         `[{"SessionKey":9140,"SessionName":"Sprint"}]`,
+			expectedName: "Sprint",
+		},
+		{
+			name:          "Legacy session with broken date",
+			data:          `{"session_key":9141,"date_start":"soon"}`,
+			expectedError: true,
+		},
+		{
+			name:         "Version 1 sessions without meeting names",
+			entryType:    "sessions",
+			version:      1,
+			data:         `[{"SessionKey":9141,"SessionName":"Race"}]`,
+			expectedName: "Race",
+		},
+		{
+			name:          "Unknown newer version",
+			entryType:     "sessions",
+			version:       7,
+			data:          `[]`,
+			expectedError: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			key := fmt.Sprintf("getsession:test:%d", i)
+			raw := fmt.Sprintf(`{"key":%q,"type":%q,"version":%d,"created_at":"2024-01-01T00:00:00Z","expires_at":"2999-01-01T00:00:00Z","data":%s}`, key, tc.entryType, tc.version, tc.data)
+			if err := os.WriteFile(filepath.Join(fc.Dir, cache.FileName(key)), []byte(raw), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var sessions []domain.Session
+			found, stale, err := fc.Get(key, &sessions)
+
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tc.expectedError, err)
+			}
+			if tc.expectedError {
+				return
+			}
+
+			if !found || len(sessions) != 1 || sessions[0].SessionName != tc.expectedName {
+				t.Errorf("expected one %s session, got found=%v %#v", tc.expectedName, found, sessions)
+			}
+			if !stale {
+				t.Error("expected migrated entry to be stale so it is refetched")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is a node in the command tree. Leaves have run; groups such as
+// cache have subcommands instead.
+type command struct {
+	name string
+	// args follows the command path in usage, e.g. "[flags] <key>...".
+	args    string
+	summary string
+	// help is the longer description shown by `pitwall help <command>`.
+	help  string
+	flags func(fs *flag.FlagSet, a *app)
+	run   func(a *app, args []string) int
+	// complete lists candidates for the next positional argument, given
+	// those already typed.
+	complete func(a *app, args []string) []string
+	// local commands run without opening the cache or creating an API
+	// client.
+	local bool
+	// rawArgs commands get their args unparsed, flags included.
+	rawArgs bool
+	// hidden commands are left out of help, e.g. the completion helper.
+	hidden      bool
+	subcommands []*command
+}
+
+func commands() *command {
+	return &command{
+		name: "pitwall",
+		args: "[global flags] <command> [flags]",
+		help: "Formula 1 session times and status from the OpenF1 API.",
+		subcommands: []*command{
+			latestCommand(),
+			getSessionCommand(),
+			weekendCommand(),
+			remindCommand(),
+			cacheCommand(),
+			configCommand(),
+			completionCommand(),
+			helpCommand(),
+			completeCommand(),
+		},
+	}
+}
+
+func (c *command) find(name string) *command {
+	for _, sub := range c.subcommands {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// lookup walks args down the tree as far as they name subcommands,
+// returning the command reached, its full path and the remaining args.
+func (c *command) lookup(args []string) (*command, string, []string) {
+	cmd, path := c, c.name
+	for len(args) > 0 {
+		sub := cmd.find(args[0])
+		if sub == nil {
+			break
+		}
+		cmd, path, args = sub, path+" "+sub.name, args[1:]
+	}
+	return cmd, path, args
+}
+
+func (c *command) flagSet(a *app, path string) *flag.FlagSet {
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	if c.flags != nil {
+		c.flags(fs, a)
+	}
+	fs.Usage = func() { c.printHelp(os.Stderr, a, path) }
+	return fs
+}
+
+func (c *command) execute(a *app, args []string) int {
+	cmd, path, rest := c.lookup(args)
+
+	if cmd.run == nil {
+		if len(rest) > 0 {
+			fmt.Fprintf(os.Stderr, "unknown command: %s\n", strings.TrimPrefix(path+" "+rest[0], c.name+" "))
+			fmt.Fprintf(os.Stderr, "Run '%s help' for usage.\n", path)
+			return exitError
+		}
+		cmd.printHelp(os.Stderr, a, path)
+		return exitError
+	}
+
+	if !cmd.rawArgs {
+		fs := cmd.flagSet(a, path)
+		if err := fs.Parse(rest); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return exitOK
+			}
+			return exitError
+		}
+		rest = fs.Args()
+	}
+
+	if !cmd.local {
+		defer a.close()
+		if err := a.setup(); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return exitError
+		}
+	}
+
+	return cmd.run(a, rest)
+}
+
+func (c *command) printHelp(w io.Writer, a *app, path string) {
+	fmt.Fprintf(w, "Usage: %s\n", strings.TrimSpace(path+" "+c.args))
+
+	if desc := c.help; desc != "" || c.summary != "" {
+		if desc == "" {
+			desc = c.summary
+		}
+		fmt.Fprintf(w, "\n%s\n", desc)
+	}
+
+	if len(c.subcommands) > 0 {
+		fmt.Fprintln(w, "\nCommands:")
+		for _, sub := range c.subcommands {
+			if !sub.hidden {
+				fmt.Fprintf(w, "  %-13s %s\n", sub.name, sub.summary)
+			}
+		}
+	}
+
+	if c.flags != nil {
+		fmt.Fprintln(w, "\nFlags:")
+		fs := c.flagSet(a, path)
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+
+	if path == "pitwall" {
+		// A fresh app keeps the values already parsed into a untouched.
+		fmt.Fprintln(w, "\nGlobal flags:")
+		fs := (&app{}).globalFlagSet()
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+		fmt.Fprintln(w, "\nRun 'pitwall help <command>' for more about a command.")
+	}
+}
+
+func helpCommand() *command {
+	return &command{
+		name:    "help",
+		args:    "[command]...",
+		summary: "Show help for a command",
+		local:   true,
+		complete: func(a *app, args []string) []string {
+			cmd, _, rest := commands().lookup(args)
+			if len(rest) > 0 {
+				return nil
+			}
+			var names []string
+			for _, sub := range cmd.subcommands {
+				if !sub.hidden {
+					names = append(names, sub.name)
+				}
+			}
+			return names
+		},
+		run: func(a *app, args []string) int {
+			root := commands()
+			cmd, path, rest := root.lookup(args)
+			if len(rest) > 0 {
+				fmt.Fprintf(os.Stderr, "unknown command: %s\n", strings.Join(args, " "))
+				return exitError
+			}
+			cmd.printHelp(os.Stdout, a, path)
+			return exitOK
+		},
+	}
+}
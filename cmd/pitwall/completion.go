@@ -0,0 +1,235 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bhopalg/pitwall/internal/config"
+)
+
+// The scripts hand the words typed so far to `pitwall __complete`, which
+// prints one candidate per line, so completion follows the command tree and
+// the cache without regenerating the scripts.
+const bashCompletion = `# bash completion for pitwall
+_pitwall() {
+    local cur=${COMP_WORDS[COMP_CWORD]}
+    local IFS=$'\n'
+    local candidates=($(pitwall __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" "$cur" 2>/dev/null))
+    COMPREPLY=()
+    local c
+    for c in "${candidates[@]}"; do
+        COMPREPLY+=("$(printf '%q' "$c")")
+    done
+}
+complete -F _pitwall pitwall
+`
+
+const zshCompletion = `#compdef pitwall
+# zsh completion for pitwall
+_pitwall() {
+    local -a candidates
+    candidates=(${(f)"$(pitwall __complete "${(@)words[2,CURRENT-1]}" "${words[CURRENT]}" 2>/dev/null)"})
+    compadd -a candidates
+}
+if [ "$funcstack[1]" = "_pitwall" ]; then
+    _pitwall "$@"
+else
+    compdef _pitwall pitwall
+fi
+`
+
+const fishCompletion = `# fish completion for pitwall
+function __pitwall_complete
+    set -l tokens (commandline -opc)
+    pitwall __complete $tokens[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c pitwall -f -a '(__pitwall_complete)'
+`
+
+var completionScripts = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// sessionTypes are the session names OpenF1 uses, offered for --type.
+var sessionTypes = []string{
+	"Practice 1", "Practice 2", "Practice 3",
+	"Qualifying", "Sprint Qualifying", "Sprint Shootout", "Sprint", "Race",
+}
+
+// flagValues complete the values of flags, wherever they appear.
+var flagValues = map[string]func(a *app) []string{
+	"country":       cachedCountries,
+	"type":          func(*app) []string { return sessionTypes },
+	"cache-backend": func(*app) []string { return []string{"file", "bolt"} },
+	"include":       func(*app) []string { return []string{"laps", "stints", "results"} },
+}
+
+func completionCommand() *command {
+	return &command{
+		name:    "completion",
+		args:    "<bash|zsh|fish>",
+		summary: "Print a shell completion script",
+		help: "Print a completion script for the shell. Load it with one of:\n\n" +
+			"  source <(pitwall completion bash)\n" +
+			"  pitwall completion zsh > \"${fpath[1]}/_pitwall\"\n" +
+			"  pitwall completion fish > ~/.config/fish/completions/pitwall.fish",
+		local: true,
+		complete: func(a *app, args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+			return []string{"bash", "zsh", "fish"}
+		},
+		run: func(a *app, args []string) int {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "usage: pitwall completion <bash|zsh|fish>")
+				return exitError
+			}
+			script, ok := completionScripts[args[0]]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "error: unsupported shell %q (want bash, zsh or fish)\n", args[0])
+				return exitError
+			}
+			fmt.Print(script)
+			return exitOK
+		},
+	}
+}
+
+// completeCommand is what the completion scripts call. Its args are the
+// words after "pitwall", the last being the partial word to complete.
+func completeCommand() *command {
+	return &command{
+		name:    "__complete",
+		local:   true,
+		rawArgs: true,
+		hidden:  true,
+		run: func(a *app, args []string) int {
+			for _, c := range complete(a, args) {
+				fmt.Println(c)
+			}
+			return exitOK
+		},
+	}
+}
+
+func complete(a *app, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	partial, words := words[len(words)-1], words[:len(words)-1]
+
+	cmd := commands()
+	fs := (&app{}).globalFlagSet()
+	var positional []string
+	var pendingFlag *flag.Flag
+
+	for _, w := range words {
+		if pendingFlag != nil {
+			pendingFlag = nil
+			continue
+		}
+		if strings.HasPrefix(w, "-") {
+			name, _, hasValue := strings.Cut(strings.TrimLeft(w, "-"), "=")
+			if f := fs.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) {
+				pendingFlag = f
+			}
+			continue
+		}
+		if sub := cmd.find(w); sub != nil && len(positional) == 0 {
+			cmd = sub
+			fs = cmd.flagSet(a, cmd.name)
+			continue
+		}
+		positional = append(positional, w)
+	}
+
+	var candidates []string
+	switch {
+	case pendingFlag != nil:
+		if values, ok := flagValues[pendingFlag.Name]; ok {
+			candidates = values(a)
+		}
+	case strings.HasPrefix(partial, "-"):
+		fs.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "--"+f.Name)
+		})
+	case len(cmd.subcommands) > 0:
+		for _, sub := range cmd.subcommands {
+			if !sub.hidden {
+				candidates = append(candidates, sub.name)
+			}
+		}
+	case cmd.complete != nil:
+		candidates = cmd.complete(a, positional)
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, partial) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// cachedKeys lists the keys in the cache, or nothing if it cannot be read.
+func cachedKeys(a *app) []string {
+	if a.cache == nil {
+		if err := a.setup(); err != nil {
+			return nil
+		}
+		defer a.close()
+	}
+
+	entries, _, err := a.cache.Info()
+	if err != nil {
+		return nil
+	}
+
+	var keys []string
+	for _, e := range entries {
+		if e.Key != "" && !e.Corrupt {
+			keys = append(keys, e.Key)
+		}
+	}
+	return keys
+}
+
+// cachedCountries lists the countries of weekends and sessions already in
+// the cache.
+func cachedCountries(a *app) []string {
+	var countries []string
+	for _, key := range cachedKeys(a) {
+		parts := strings.Split(key, ":")
+		if len(parts) < 3 || (parts[0] != "weekend" && parts[0] != "getsession") {
+			continue
+		}
+		if !slices.Contains(countries, parts[1]) {
+			countries = append(countries, parts[1])
+		}
+	}
+	slices.Sort(countries)
+	return countries
+}
+
+func settingKeys(a *app, args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	keys := []string{"profile"}
+	for _, s := range config.Settings {
+		keys = append(keys, s.Key)
+	}
+	return keys
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bhopalg/pitwall/internal/config"
+)
+
+// configCommand handles `pitwall config get|set|list`. get and list show the
+// profile's effective values, including those it takes from the default
+// profile; set only changes the selected profile.
+func configCommand() *command {
+	return &command{
+		name:    "config",
+		args:    "<command>",
+		summary: "Read and change settings",
+		help:    "Read and change settings in the config file. --profile picks the profile.",
+		subcommands: []*command{
+			{
+				name:    "list",
+				summary: "Show every setting of the profile",
+				local:   true,
+				run:     configList,
+			},
+			{
+				name:     "get",
+				args:     "<key>",
+				summary:  "Print one setting",
+				help:     "Print a setting of the profile. Exits 1 if it is unset. 'profile' prints the\nprofile in use.",
+				local:    true,
+				complete: settingKeys,
+				run:      configGet,
+			},
+			{
+				name:    "set",
+				args:    "<key> <value>",
+				summary: "Change one setting",
+				help: "Change a setting of the profile; an empty value unsets it. 'profile' picks\n" +
+					"the profile used when --profile is not given.\n\nKeys:\n" + settingsHelp(),
+				local:    true,
+				complete: settingKeys,
+				run:      configSet,
+			},
+		},
+	}
+}
+
+func configList(a *app, args []string) int {
+	if a.profileErr != nil {
+		fmt.Fprintln(os.Stderr, "error:", a.profileErr)
+		return exitError
+	}
+
+	fmt.Printf("Config File: %s\n", a.configPath)
+	fmt.Printf("Profile:     %s\n", a.config.ProfileName(a.flags.profile))
+	if names := a.config.Names(); len(names) > 0 {
+		fmt.Printf("Profiles:    %s\n", strings.Join(names, ", "))
+	}
+	fmt.Println()
+
+	for _, s := range config.Settings {
+		value, _ := config.Get(&a.profile, s.Key)
+		fmt.Printf("%-22s %s\n", s.Key, value)
+	}
+	return exitOK
+}
+
+func configGet(a *app, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pitwall config get <key>")
+		return exitError
+	}
+	if args[0] == "profile" {
+		fmt.Println(a.config.ProfileName(a.flags.profile))
+		return exitOK
+	}
+
+	if a.profileErr != nil {
+		fmt.Fprintln(os.Stderr, "error:", a.profileErr)
+		return exitError
+	}
+	value, err := config.Get(&a.profile, args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+	if value == "" {
+		return exitNoResult
+	}
+	fmt.Println(value)
+	return exitOK
+}
+
+func configSet(a *app, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: pitwall config set <key> <value>")
+		return exitError
+	}
+
+	if args[0] == "profile" {
+		a.config.Profile = args[1]
+		a.config.Edit(args[1])
+	} else if err := config.Set(a.config.Edit(a.flags.profile), args[0], args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+
+	if err := a.config.Save(a.configPath); err != nil {
+		fmt.Fprintln(os.Stderr, "error: writing config:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func settingsHelp() string {
+	var b strings.Builder
+	for _, s := range config.Settings {
+		fmt.Fprintf(&b, "  %-22s %s\n", s.Key, s.Usage)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
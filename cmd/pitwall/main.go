@@ -1,221 +1,174 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
 	"time"
 
-	"github.com/bhopalg/pitwall/domain"
 	"github.com/bhopalg/pitwall/internal/cache"
 	"github.com/bhopalg/pitwall/internal/openf1"
-	"github.com/bhopalg/pitwall/internal/services/getsession"
-	"github.com/bhopalg/pitwall/internal/services/latest"
-	"github.com/bhopalg/pitwall/internal/services/remind"
-	"github.com/bhopalg/pitwall/internal/services/weekend"
-	"github.com/bhopalg/pitwall/utils"
 )
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("useafe: putwall <command>")
-		fmt.Println("commands: get_session")
-		os.Exit(1)
-	}
-
-	now := time.Now().UTC()
-	fileCache := &cache.FileCache{Dir: "../../.pitwall_cache"}
-
-	getSessionCmd := flag.NewFlagSet("get_session", flag.ExitOnError)
-
-	ctx, canel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer canel()
-
-	openf1Client := openf1.New()
-
-	switch os.Args[1] {
-	case "remind":
-		remindCmd := flag.NewFlagSet("remind", flag.ExitOnError)
-		threshold := remindCmd.Int("minutes", 30, "minutes threshold for reminder")
-		quiet := remindCmd.Bool("quiet", false, "suppress output if no reminder")
-
-		remindCmd.Parse(os.Args[2:])
-
-		service := latest.New(openf1Client, fileCache)
-		res, err := service.Next(ctx)
-
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(2)
-		}
-
-		if res.Session == nil {
-			if !*quiet {
-				fmt.Println("No upcoming sessions found.")
-			}
-			os.Exit(1)
-		}
+// Exit codes shared by every command.
+const (
+	exitOK = 0
+	// exitNoResult means the command worked but found nothing, e.g. no
+	// session matched or no reminder is due.
+	exitNoResult = 1
+	// exitError covers bad usage and failures.
+	exitError = 2
+)
 
-		trigger, diff := remind.ShouldRemind(now, res.Session.DateStart, *threshold)
+// env holds what run needs from outside the process, so tests can point the
+// CLI at a fake OpenF1 server and a temporary cache.
+type env struct {
+	baseURL string
+	// configPath is used when neither --config nor PITWALL_CONFIG is set.
+	// Empty means config.DefaultPath.
+	configPath string
+	// cacheDir is used when neither --cache-dir nor PITWALL_CACHE_DIR is
+	// set. Empty means the user cache directory from cache.DefaultDir.
+	cacheDir string
+	// legacyCacheDirs are relative cache directories from older versions,
+	// moved into the default cache directory when found.
+	legacyCacheDirs []string
+	// loc is the zone times are shown in when neither --tz nor the config
+	// picks one, if not defaultTimeZone.
+	loc *time.Location
+	now func() time.Time
+}
 
-		if trigger {
-			fmt.Printf("REMIND: %s starts in %v!\n", res.Session.SessionName, diff.Round(time.Minute))
-			os.Exit(0)
-		}
+func main() {
+	os.Exit(run(os.Args[1:], env{
+		baseURL:         openf1.DefaultBaseURL,
+		legacyCacheDirs: []string{".pitwall_cache"},
+		now:             time.Now,
+	}))
+}
 
-		if !*quiet {
-			fmt.Printf("No reminder needed. Next session (%s) is in %v.\n",
-				res.Session.SessionName, diff.Round(time.Minute))
-		}
-		os.Exit(1)
-	case "cache":
-		if len(os.Args) < 3 {
-			fmt.Println("usage: pitwall cache <info|clear>")
-			return
-		}
+func run(argv []string, e env) int {
+	root := commands()
+	a := &app{env: e, now: e.now().UTC()}
 
-		subCommand := os.Args[2]
-
-		switch subCommand {
-		case "info":
-			entries, path, err := fileCache.Info()
-			if err != nil {
-				fmt.Printf("Error reading cache: %v\n", err)
-				return
-			}
-
-			fmt.Printf("Cache Location: %s\n", path)
-			fmt.Printf("Total Entries:  %d\n\n", len(entries))
-
-			if len(entries) > 0 {
-				fmt.Printf("%-30s %-20s %-10s %-10s\n", "KEY", "CREATED AT", "STALE", "SIZE")
-				for _, e := range entries {
-					staleStr := "no"
-					if e.IsStale {
-						staleStr = "YES"
-					}
-					fmt.Printf("%-30s %-20s %-10s %-10d B\n",
-						e.Key,
-						e.CreatedAt.Format("02 Jan 15:04"),
-						staleStr,
-						e.Size,
-					)
-				}
-			}
-		case "clear":
-			count, err := fileCache.Clear()
-			if err != nil {
-				fmt.Printf("Error clearing cache: %v\n", err)
-				return
-			}
-			if count == 0 {
-				fmt.Println("Nothing to clear.")
-			} else {
-				fmt.Printf("Successfully removed %d cache entries.\n", count)
-			}
-		default:
-			fmt.Printf("unknown cache command: %s\n", subCommand)
+	globalFlags := a.globalFlagSet()
+	globalFlags.Usage = func() { root.printHelp(os.Stderr, a, "pitwall") }
+	if err := globalFlags.Parse(argv); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
 		}
+		return exitError
+	}
 
-	case "weekend":
-		country := getSessionCmd.String("country", "Belgium", "country name for session")
-		session_year := getSessionCmd.String("year", "2023", "session year")
-
-		getSessionCmd.Parse(os.Args[2:])
-
-		service := weekend.New(openf1Client, fileCache)
-		sessions, err := service.Weekend(ctx, *country, *session_year)
-
-		if err != nil {
-			fmt.Println("error:", err)
-			return
-		}
+	if err := a.envError(globalFlags); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
 
-		if sessions.Sessions != nil && sessions.Warning != "" {
-			fmt.Println(sessions.Warning)
-		}
+	if err := a.loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
 
-		if sessions.Sessions == nil || len(*sessions.Sessions) == 0 {
-			fmt.Println("No sessions found.")
-			return
-		}
+	return root.execute(a, globalFlags.Args())
+}
 
-		firstSession := (*sessions.Sessions)[0]
-		fmt.Printf("%s Grand Prix - %s\n\n", firstSession.CountryName, firstSession.CircuitName)
+// resolveCacheDir picks the cache directory: the flag or PITWALL_CACHE_DIR,
+// then env.cacheDir, then the user cache directory. Legacy relative caches
+// are only moved into the user cache directory, never into one chosen
+// explicitly, and only while it does not exist yet, so this happens once.
+func resolveCacheDir(flagDir string, e env) (string, error) {
+	if flagDir != "" {
+		return flagDir, nil
+	}
+	if e.cacheDir != "" {
+		return e.cacheDir, nil
+	}
 
-		groupSessions := createWeekendGroup(*sessions.Sessions)
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return "", fmt.Errorf("finding cache directory: %w (set --cache-dir or PITWALL_CACHE_DIR)", err)
+	}
 
-		orderedDays := []string{"Fri", "Sat", "Sun"}
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
 
-		for _, day := range orderedDays {
-			if s, ok := groupSessions[day]; ok {
-				fmt.Printf("%s\n", day)
-				for _, session := range s {
-					fmt.Printf("\t%s\t%s\n", session.SessionName, session.DateStart.Format("15:04"))
-				}
-				fmt.Println()
-			}
+	for _, legacy := range e.legacyCacheDirs {
+		if info, err := os.Stat(legacy); err != nil || !info.IsDir() {
+			continue
 		}
-
-	case "latest":
-		service := latest.New(openf1Client, fileCache)
-		s, err := service.Next(ctx)
+		n, err := cache.MoveDir(legacy, dir)
 		if err != nil {
-			fmt.Println("error:", err)
-			return
+			fmt.Fprintf(os.Stderr, "⚠️ Could not move cache from %s to %s: %v\n", legacy, dir, err)
+			continue
 		}
-
-		if s.Session == nil {
-			fmt.Println("No session found.")
-			return
-		}
-
-		fmt.Printf("%s - %s (%s)\n", s.Session.SessionName, s.Session.CircuitName, s.Session.CountryName)
-		utils.PrintSessionStatus(s.Session, now)
-
-	case "get_session":
-		country := getSessionCmd.String("country", "Belgium", "country name for session")
-		session_type := getSessionCmd.String("type", "Sprint", "session type e.g. Sprint, Race")
-		session_year := getSessionCmd.String("year", "2023", "session year")
-
-		getSessionCmd.Parse(os.Args[2:])
-
-		service := getsession.New(openf1Client, fileCache)
-		s, err := service.GetSession(ctx, *country, *session_type, *session_year)
-		if err != nil {
-			fmt.Println("error:", err)
-			return
+		if n > 0 {
+			fmt.Fprintf(os.Stderr, "Moved %d cache entries from %s to %s\n", n, legacy, dir)
 		}
+	}
+	return dir, nil
+}
 
-		if s.Session != nil && s.Warning != "" {
-			fmt.Println(s.Warning)
-		}
+// setDefault fills a flag that was given neither on the command line nor in
+// the environment.
+func setDefault(flagValue *string, value string) {
+	if *flagValue == "" {
+		*flagValue = value
+	}
+}
 
-		if s.Session == nil {
-			fmt.Println("No sessions found.")
-			return
-		}
+// envInt reads a non-negative integer setting for the flag flagName from
+// the environment, recording invalid values for envError.
+func (a *app) envInt(flagName, name string) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		a.setEnvError(flagName, fmt.Errorf("invalid %s %q: want a whole number of 0 or more", name, v))
+		return 0
+	}
+	return n
+}
 
-		if s.Session != nil && s.Warning != "" {
-			fmt.Println(s.Warning)
-		}
+// envBool reads a boolean setting for the flag flagName from the environment
+// variable name. Unset is false; a value strconv.ParseBool rejects is
+// reported by envError.
+func (a *app) envBool(flagName, name string) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		a.setEnvError(flagName, fmt.Errorf("invalid %s %q: want true or false", name, v))
+	}
+	return b
+}
 
-		fmt.Printf("%s - %s (%s)\n", s.Session.SessionName, s.Session.CircuitName, s.Session.CountryName)
-		fmt.Printf("Starts: %s (UTC)\n", s.Session.DateStart.Format(time.RFC1123))
-	default:
-		fmt.Printf("unknown command: %s\n", os.Args[1])
-		os.Exit(1)
+func (a *app) setEnvError(flagName string, err error) {
+	if a.envErrs == nil {
+		a.envErrs = make(map[string]error)
 	}
+	a.envErrs[flagName] = err
 }
 
-func createWeekendGroup(session []domain.Session) map[string][]domain.Session {
-	sessionsByDay := make(map[string][]domain.Session)
+// envError reports invalid environment settings, except for flags given on
+// the command line, which override them.
+func (a *app) envError(fs *flag.FlagSet) error {
+	fs.Visit(func(f *flag.Flag) {
+		delete(a.envErrs, f.Name)
+	})
 
-	for _, s := range session {
-		dayKey := s.DateStart.Format("Mon")
-		sessionsByDay[dayKey] = append(sessionsByDay[dayKey], s)
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(a.envErrs)) {
+		errs = append(errs, a.envErrs[name])
 	}
-
-	return sessionsByDay
+	return errors.Join(errs...)
 }
@@ -0,0 +1,757 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+	"github.com/bhopalg/pitwall/internal/cache"
+	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/openf1/openf1test"
+	"github.com/bhopalg/pitwall/utils"
+)
+
+var belgium2023 = []openf1.Session{
+	{SessionKey: 9133, SessionName: "Practice 1", DateStart: "2023-07-28T11:30:00+00:00", DateEnd: "2023-07-28T12:30:00+00:00", Location: "Spa-Francorchamps", CountryName: "Belgium", CircuitName: "Spa-Francorchamps", MeetingKey: 1216, Year: 2023, GMTOffset: "02:00:00"},
+	{SessionKey: 9134, SessionName: "Qualifying", DateStart: "2023-07-28T15:00:00+00:00", DateEnd: "2023-07-28T16:00:00+00:00", Location: "Spa-Francorchamps", CountryName: "Belgium", CircuitName: "Spa-Francorchamps", MeetingKey: 1216, Year: 2023, GMTOffset: "02:00:00"},
+	{SessionKey: 9135, SessionName: "Sprint Shootout", DateStart: "2023-07-29T10:30:00+00:00", DateEnd: "2023-07-29T11:14:00+00:00", Location: "Spa-Francorchamps", CountryName: "Belgium", CircuitName: "Spa-Francorchamps", MeetingKey: 1216, Year: 2023, GMTOffset: "02:00:00"},
+	{SessionKey: 9140, SessionName: "Sprint", DateStart: "2023-07-29T15:05:00+00:00", DateEnd: "2023-07-29T15:35:00+00:00", Location: "Spa-Francorchamps", CountryName: "Belgium", CircuitName: "Spa-Francorchamps", MeetingKey: 1216, Year: 2023, GMTOffset: "02:00:00"},
+	{SessionKey: 9141, SessionName: "Race", DateStart: "2023-07-30T13:00:00+00:00", DateEnd: "2023-07-30T15:00:00+00:00", Location: "Spa-Francorchamps", CountryName: "Belgium", CircuitName: "Spa-Francorchamps", MeetingKey: 1216, Year: 2023, GMTOffset: "02:00:00"},
+}
+
+func newTestEnv(t *testing.T) (*openf1test.Server, env) {
+	t.Helper()
+
+	srv := openf1test.NewServer(t, nil)
+	for _, s := range belgium2023 {
+		srv.Add("/sessions", s)
+	}
+	srv.Add("/meetings", openf1.Meeting{MeetingKey: 1216, MeetingName: "Belgian Grand Prix", CountryName: "Belgium", GMTOffset: "02:00:00", Year: 2023})
+
+	return srv, env{
+		baseURL:    srv.URL,
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		cacheDir:   t.TempDir(),
+		loc:        time.UTC,
+		now: func() time.Time {
+			return time.Date(2023, 7, 30, 14, 0, 0, 0, time.UTC)
+		},
+	}
+}
+
+func runCapture(t *testing.T, e env, args ...string) (string, int) {
+	t.Helper()
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stdout, os.Stderr = w, w
+
+	code := run(args, e)
+
+	w.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return buf.String(), code
+}
+
+func TestCommands(t *testing.T) {
+	testcases := []struct {
+		name           string
+		args           []string
+		expectedCode   int
+		expectedOutput []string
+	}{
+		{
+			name:           "get_session",
+			args:           []string{"get_session", "--country", "Belgium", "--type", "Race", "--year", "2023"},
+			expectedOutput: []string{"Race - Spa-Francorchamps (Belgium)", "Starts: Sun, 30 Jul 2023 13:00:00 UTC"},
+		},
+		{
+			name:           "get_session no match",
+			args:           []string{"get_session", "--country", "Monaco", "--type", "Race", "--year", "2023"},
+			expectedCode:   1,
+			expectedOutput: []string{"No sessions found."},
+		},
+		{
+			name: "weekend",
+			args: []string{"weekend", "--country", "Belgium", "--year", "2023"},
+			expectedOutput: []string{
+				"Belgian Grand Prix - Spa-Francorchamps\nFormat: sprint",
+				"Fri 28 Jul\n\tPractice 1\t11:30 UTC\tFinished\tended 2d 1h 30m ago",
+				"Sat 29 Jul\n\tSprint Shootout",
+				"Sun 30 Jul\n\tRace\t13:00 UTC\tLive\tends in 1h 0m",
+			},
+		},
+		{
+			name:           "latest",
+			args:           []string{"latest"},
+			expectedOutput: []string{"Race - Spa-Francorchamps (Belgium)", "Status: Live", "Ends in: 1h 0m"},
+		},
+		{
+			name:           "remind with nothing upcoming",
+			args:           []string{"remind"},
+			expectedCode:   1,
+			expectedOutput: []string{"No reminder needed."},
+		},
+		{
+			name:           "unknown command",
+			args:           []string{"podium"},
+			expectedCode:   2,
+			expectedOutput: []string{"unknown command: podium"},
+		},
+		{
+			name:           "unknown subcommand",
+			args:           []string{"cache", "shred"},
+			expectedCode:   2,
+			expectedOutput: []string{"unknown command: cache shred"},
+		},
+		{
+			name:           "no command",
+			args:           []string{},
+			expectedCode:   2,
+			expectedOutput: []string{"Usage: pitwall [global flags] <command> [flags]", "get_session", "-cache-dir"},
+		},
+		{
+			name:           "help",
+			args:           []string{"help", "weekend"},
+			expectedOutput: []string{"Usage: pitwall weekend [flags]", "-country", "-year"},
+		},
+		{
+			name:           "help for a subcommand",
+			args:           []string{"help", "cache", "prune"},
+			expectedOutput: []string{"Usage: pitwall cache prune [flags]", "-older-than"},
+		},
+		{
+			name:           "command help flag",
+			args:           []string{"remind", "-h"},
+			expectedOutput: []string{"Usage: pitwall remind [flags]", "-minutes"},
+		},
+		{
+			name:           "bad flag",
+			args:           []string{"weekend", "--colour", "red"},
+			expectedCode:   2,
+			expectedOutput: []string{"flag provided but not defined: -colour"},
+		},
+		{
+			name:           "completion script",
+			args:           []string{"completion", "zsh"},
+			expectedOutput: []string{"#compdef pitwall", "pitwall __complete"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, e := newTestEnv(t)
+
+			output, code := runCapture(t, e, tc.args...)
+
+			if code != tc.expectedCode {
+				t.Errorf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			for _, expected := range tc.expectedOutput {
+				if !strings.Contains(output, expected) {
+					t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+				}
+			}
+		})
+	}
+}
+
+func TestWeekendStaleCacheFallback(t *testing.T) {
+	srv, e := newTestEnv(t)
+
+	stale := []domain.Session{{SessionName: "Cached Race", CountryName: "Belgium", CircuitName: "Spa-Francorchamps"}}
+	if err := (&cache.FileCache{Dir: e.cacheDir}).Set("weekend:Belgium:2023", stale, -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	srv.Fail("/sessions", http.StatusInternalServerError)
+
+	output, _ := runCapture(t, e, "weekend", "--country", "Belgium", "--year", "2023")
+
+	if !strings.Contains(output, "API unavailable. Showing stale cached data.") {
+		t.Errorf("expected stale data warning, got:\n%s", output)
+	}
+	if srv.Requests("/sessions") != 1 {
+		t.Errorf("expected one API attempt, got %d", srv.Requests("/sessions"))
+	}
+}
+
+func TestWeekendServedFromCache(t *testing.T) {
+	srv, e := newTestEnv(t)
+
+	runCapture(t, e, "weekend", "--country", "Belgium", "--year", "2023")
+	output, _ := runCapture(t, e, "weekend", "--country", "Belgium", "--year", "2023")
+
+	if srv.Requests("/sessions") != 1 {
+		t.Errorf("expected the second run to be served from cache, got %d API calls", srv.Requests("/sessions"))
+	}
+	if !strings.Contains(output, "Belgian Grand Prix") {
+		t.Errorf("expected cached weekend output, got:\n%s", output)
+	}
+}
+
+func TestWeekendStaleWhileRevalidate(t *testing.T) {
+	srv, e := newTestEnv(t)
+
+	stale := []domain.Session{{SessionName: "Cached Race", CountryName: "Belgium", CircuitName: "Spa-Francorchamps"}}
+	if err := (&cache.FileCache{Dir: e.cacheDir}).Set("weekend:Belgium:2023", stale, -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	output, _ := runCapture(t, e, "--stale-while-revalidate", "weekend", "--country", "Belgium", "--year", "2023")
+	if !strings.Contains(output, "refreshes in the background") {
+		t.Errorf("expected background refresh warning, got:\n%s", output)
+	}
+
+	// The refresh is flushed before run returns, so the next run is fresh.
+	output, _ = runCapture(t, e, "weekend", "--country", "Belgium", "--year", "2023")
+	if strings.Contains(output, "stale") {
+		t.Errorf("expected refreshed data, got:\n%s", output)
+	}
+	if srv.Requests("/sessions") != 1 {
+		t.Errorf("expected one background API call, got %d", srv.Requests("/sessions"))
+	}
+
+	t.Setenv("PITWALL_STALE_WHILE_REVALIDATE", "sometimes")
+	output, code := runCapture(t, e, "weekend", "--country", "Belgium", "--year", "2023")
+	if code != 2 || !strings.Contains(output, `invalid PITWALL_STALE_WHILE_REVALIDATE "sometimes"`) {
+		t.Errorf("expected an invalid setting error, got %d:\n%s", code, output)
+	}
+}
+
+func TestCacheMaxEntriesEnv(t *testing.T) {
+	_, e := newTestEnv(t)
+
+	testcases := []struct {
+		name     string
+		env      string
+		args     []string
+		expected string
+		code     int
+	}{
+		{name: "Invalid number", env: "lots", expected: `invalid PITWALL_CACHE_MAX_ENTRIES "lots"`, code: 2},
+		{name: "Negative number", env: "-5", expected: `invalid PITWALL_CACHE_MAX_ENTRIES "-5"`, code: 2},
+		{name: "Flag overrides invalid environment", env: "lots", args: []string{"--cache-max-entries=10"}, expected: "Cache Location"},
+		{name: "Valid number", env: "10", expected: "Cache Location"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("PITWALL_CACHE_MAX_ENTRIES", tc.env)
+
+			args := append(tc.args, "cache", "info")
+			output, code := runCapture(t, e, args...)
+			if code != tc.code || !strings.Contains(output, tc.expected) {
+				t.Errorf("expected %q and exit %d, got %d:\n%s", tc.expected, tc.code, code, output)
+			}
+		})
+	}
+}
+
+func TestCachePruneAndRemove(t *testing.T) {
+	_, e := newTestEnv(t)
+
+	fc := &cache.FileCache{Dir: e.cacheDir}
+	_ = fc.Set("weekend:Belgium:2023", []domain.Session{{SessionName: "Race"}}, -time.Minute)
+	_ = fc.Set("weekend:Monaco:2023", []domain.Session{{SessionName: "Race"}}, time.Hour)
+
+	output, code := runCapture(t, e, "cache", "prune", "--stale", "--prefix", "weekend:")
+	if code != 0 || !strings.Contains(output, "Pruned 1 cache entries.") {
+		t.Errorf("expected one stale entry pruned, got %d:\n%s", code, output)
+	}
+
+	output, code = runCapture(t, e, "cache", "rm", "weekend:Monaco:2023")
+	if code != 0 || !strings.Contains(output, "Removed weekend:Monaco:2023.") {
+		t.Errorf("expected entry removed, got %d:\n%s", code, output)
+	}
+
+	output, code = runCapture(t, e, "cache", "rm", "weekend:Monaco:2023")
+	if code != 1 || !strings.Contains(output, "No cache entry for weekend:Monaco:2023.") {
+		t.Errorf("expected missing entry, got %d:\n%s", code, output)
+	}
+}
+
+func TestBoltCacheBackend(t *testing.T) {
+	srv, e := newTestEnv(t)
+
+	runCapture(t, e, "--cache-backend", "bolt", "weekend", "--country", "Belgium", "--year", "2023")
+	output, _ := runCapture(t, e, "--cache-backend", "bolt", "weekend", "--country", "Belgium", "--year", "2023")
+
+	if srv.Requests("/sessions") != 1 {
+		t.Errorf("expected the second run to be served from the database, got %d API calls", srv.Requests("/sessions"))
+	}
+	if !strings.Contains(output, "Belgian Grand Prix") {
+		t.Errorf("expected cached weekend output, got:\n%s", output)
+	}
+
+	output, _ = runCapture(t, e, "--cache-backend", "bolt", "cache", "info")
+	if !strings.Contains(output, cache.BoltFile) || !strings.Contains(output, "weekend:Belgium:2023") {
+		t.Errorf("expected database info, got:\n%s", output)
+	}
+
+	if _, code := runCapture(t, e, "--cache-backend", "sqlite", "latest"); code != 2 {
+		t.Errorf("expected unknown backend to exit 2, got %d", code)
+	}
+}
+
+func TestCacheExportImport(t *testing.T) {
+	_, e := newTestEnv(t)
+
+	fc := &cache.FileCache{Dir: e.cacheDir}
+	_ = fc.Set("weekend:Belgium:2023", []domain.Session{{SessionName: "Race"}}, time.Hour)
+	_ = fc.Set("weekend:Belgium:2024", []domain.Session{{SessionName: "Race"}}, time.Hour)
+
+	bundle := filepath.Join(t.TempDir(), "season.tar.gz")
+	output, code := runCapture(t, e, "cache", "export", "--year", "2023", "-o", bundle)
+	if code != 0 || !strings.Contains(output, "Exported 1 cache entries") {
+		t.Fatalf("expected one entry exported, got %d:\n%s", code, output)
+	}
+
+	before, _ := os.ReadFile(bundle)
+	if output, code := runCapture(t, e, "cache", "export", "-o", bundle); code != 2 || !strings.Contains(output, "already exists") {
+		t.Errorf("expected export to refuse an existing bundle, got %d:\n%s", code, output)
+	}
+	if after, _ := os.ReadFile(bundle); !bytes.Equal(before, after) {
+		t.Error("expected the existing bundle to be left alone")
+	}
+	if _, code := runCapture(t, e, "cache", "export", "--year", "2023", "--force", "-o", bundle); code != 0 {
+		t.Errorf("expected --force to overwrite the bundle, got %d", code)
+	}
+
+	_, other := newTestEnv(t)
+	output, code = runCapture(t, other, "cache", "import", bundle)
+	if code != 0 || !strings.Contains(output, "Imported 1 of 1 cache entries.") {
+		t.Fatalf("expected one entry imported, got %d:\n%s", code, output)
+	}
+
+	var sessions []domain.Session
+	if found, _, _ := (&cache.FileCache{Dir: other.cacheDir}).Get("weekend:Belgium:2023", &sessions); !found {
+		t.Error("expected imported entry in the new cache")
+	}
+}
+
+func TestCacheWarm(t *testing.T) {
+	srv, e := newTestEnv(t)
+	srv.Add("/meetings", openf1.Meeting{MeetingKey: 1216, MeetingName: "Belgian Grand Prix", CountryName: "Belgium", Year: 2023})
+
+	output, code := runCapture(t, e, "cache", "warm", "--year", "2023")
+	if code != 0 || !strings.Contains(output, "[1/1] weekend:Belgium:2023 fetched") {
+		t.Fatalf("expected the weekend to be warmed, got %d:\n%s", code, output)
+	}
+
+	requests := srv.Requests("/sessions")
+	output, _ = runCapture(t, e, "get_session", "--country", "Belgium", "--type", "Sprint", "--year", "2023")
+	if !strings.Contains(output, "Sprint - Spa-Francorchamps (Belgium)") {
+		t.Errorf("expected warmed session, got:\n%s", output)
+	}
+	if srv.Requests("/sessions") != requests {
+		t.Error("expected get_session to be served from the warmed cache")
+	}
+}
+
+func TestOffline(t *testing.T) {
+	srv, e := newTestEnv(t)
+
+	runCapture(t, e, "weekend", "--country", "Belgium", "--year", "2023")
+	if err := (&cache.FileCache{Dir: e.cacheDir}).Set("weekend:Belgium:2023", belgiumSessions(t), -time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	requests := srv.Requests("/sessions")
+
+	output, _ := runCapture(t, e, "--offline", "weekend", "--country", "Belgium", "--year", "2023")
+	if !strings.Contains(output, "📴 Offline: showing data cached") || !strings.Contains(output, "Belgium Grand Prix") {
+		t.Errorf("expected stale weekend served offline, got:\n%s", output)
+	}
+
+	output, _ = runCapture(t, e, "--offline", "weekend", "--country", "Monaco", "--year", "2023")
+	if !strings.Contains(output, "weekend:Monaco:2023 is not cached") {
+		t.Errorf("expected a not cached error, got:\n%s", output)
+	}
+
+	if srv.Requests("/sessions") != requests {
+		t.Error("expected no API calls while offline")
+	}
+
+	// The API names the meeting "Belgian Grand Prix", the cached entry
+	// "Belgium Grand Prix".
+	testcases := []struct {
+		name     string
+		env      string
+		args     []string
+		expected string
+		code     int
+	}{
+		{name: "Environment true", env: "true", expected: "📴 Offline"},
+		{name: "Environment 1", env: "1", expected: "📴 Offline"},
+		{name: "Environment false", env: "false", expected: "Belgian Grand Prix"},
+		{name: "Environment 0", env: "0", expected: "Belgian Grand Prix"},
+		{name: "Invalid environment", env: "yes please", expected: `invalid PITWALL_OFFLINE "yes please"`, code: 2},
+		{name: "Flag overrides invalid environment", env: "yes please", args: []string{"--offline=false"}, expected: "Belgian Grand Prix"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("PITWALL_OFFLINE", tc.env)
+
+			args := append(tc.args, "weekend", "--country", "Belgium", "--year", "2023")
+			output, code := runCapture(t, e, args...)
+			if code != tc.code || !strings.Contains(output, tc.expected) {
+				t.Errorf("expected %q and exit %d, got %d:\n%s", tc.expected, tc.code, code, output)
+			}
+		})
+	}
+}
+
+func belgiumSessions(t *testing.T) []domain.Session {
+	t.Helper()
+
+	var sessions []domain.Session
+	for _, s := range belgium2023 {
+		mapped, err := utils.MapToDomain(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, *mapped)
+	}
+	return sessions
+}
+
+func TestCacheDir(t *testing.T) {
+	t.Run("Legacy cache moves to the user cache directory", func(t *testing.T) {
+		srv, e := newTestEnv(t)
+		legacy := filepath.Join(t.TempDir(), ".pitwall_cache")
+		if err := (&cache.FileCache{Dir: legacy}).Set("weekend:Belgium:2023", belgiumSessions(t), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		xdg := t.TempDir()
+		t.Setenv("XDG_CACHE_HOME", xdg)
+		t.Setenv("PITWALL_CACHE_DIR", "")
+		e.cacheDir = ""
+		e.legacyCacheDirs = []string{legacy}
+
+		output, _ := runCapture(t, e, "weekend", "--country", "Belgium", "--year", "2023")
+		if !strings.Contains(output, "Belgium Grand Prix") {
+			t.Errorf("expected weekend output, got:\n%s", output)
+		}
+		if srv.Requests("/sessions") != 0 {
+			t.Error("expected the moved entry to be served from cache")
+		}
+		if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+			t.Errorf("expected legacy cache to be removed, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(xdg, "pitwall")); err != nil {
+			t.Errorf("expected cache under XDG_CACHE_HOME, got %v", err)
+		}
+	})
+
+	t.Run("Legacy cache is left alone once the user cache directory exists", func(t *testing.T) {
+		_, e := newTestEnv(t)
+		legacy := filepath.Join(t.TempDir(), ".pitwall_cache")
+		if err := (&cache.FileCache{Dir: legacy}).Set("weekend:Belgium:2023", belgiumSessions(t), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		notes := filepath.Join(legacy, "my-notes.txt")
+		if err := os.WriteFile(notes, []byte("keep"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		xdg := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(xdg, "pitwall"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("XDG_CACHE_HOME", xdg)
+		t.Setenv("PITWALL_CACHE_DIR", "")
+		e.cacheDir = ""
+		e.legacyCacheDirs = []string{legacy}
+
+		output, _ := runCapture(t, e, "cache", "info")
+		if strings.Contains(output, "Moved") {
+			t.Errorf("expected nothing moved, got:\n%s", output)
+		}
+		entries, _ := os.ReadDir(legacy)
+		if _, err := os.Stat(notes); err != nil || len(entries) < 2 {
+			t.Errorf("expected the legacy cache to be untouched, got %d files, %v", len(entries), err)
+		}
+	})
+
+	t.Run("--cache-dir overrides the default", func(t *testing.T) {
+		_, e := newTestEnv(t)
+		dir := t.TempDir()
+
+		runCapture(t, e, "--cache-dir", dir, "weekend", "--country", "Belgium", "--year", "2023")
+
+		var sessions []domain.Session
+		if found, _, _ := (&cache.FileCache{Dir: dir}).Get("weekend:Belgium:2023", &sessions); !found {
+			t.Error("expected weekend cached in --cache-dir")
+		}
+	})
+}
+
+func TestConfig(t *testing.T) {
+	_, e := newTestEnv(t)
+
+	if output, code := runCapture(t, e, "weekend"); code != 2 || !strings.Contains(output, "no country given") {
+		t.Errorf("expected an error without a country, got %d:\n%s", code, output)
+	}
+
+	for _, kv := range [][]string{{"country", "Belgium"}, {"year", "2023"}, {"session_type", "Sprint"}} {
+		if output, code := runCapture(t, e, "config", "set", kv[0], kv[1]); code != 0 {
+			t.Fatalf("config set %s: %s", kv[0], output)
+		}
+	}
+	if output, code := runCapture(t, e, "config", "set", "cache.backend", "sqlite"); code != 2 || !strings.Contains(output, "unknown cache backend") {
+		t.Errorf("expected invalid value to be rejected, got %d:\n%s", code, output)
+	}
+
+	output, _ := runCapture(t, e, "get_session")
+	if !strings.Contains(output, "Sprint - Spa-Francorchamps (Belgium)") {
+		t.Errorf("expected defaults from config, got:\n%s", output)
+	}
+
+	t.Run("Profiles", func(t *testing.T) {
+		runCapture(t, e, "--profile", "monaco", "config", "set", "country", "Monaco")
+
+		output, _ := runCapture(t, e, "--profile", "monaco", "config", "list")
+		if !strings.Contains(output, "Profile:     monaco") || !strings.Contains(output, "year                   2023") {
+			t.Errorf("expected monaco profile with default year, got:\n%s", output)
+		}
+
+		output, _ = runCapture(t, e, "--profile", "monaco", "config", "get", "country")
+		if strings.TrimSpace(output) != "Monaco" {
+			t.Errorf("expected Monaco, got %q", output)
+		}
+
+		runCapture(t, e, "config", "set", "profile", "monaco")
+		output, _ = runCapture(t, e, "config", "get", "country")
+		if strings.TrimSpace(output) != "Monaco" {
+			t.Errorf("expected the monaco profile to be selected, got %q", output)
+		}
+
+		if _, code := runCapture(t, e, "--profile", "home", "latest"); code != 2 {
+			t.Errorf("expected exit code 2 for an unknown profile, got %d", code)
+		}
+	})
+}
+
+func TestComplete(t *testing.T) {
+	_, e := newTestEnv(t)
+	runCapture(t, e, "weekend", "--country", "Belgium", "--year", "2023")
+	if err := (&cache.FileCache{Dir: e.cacheDir}).Set("getsession:Monaco:2023:Race", []domain.Session{}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		words    []string
+		expected []string
+	}{
+		{name: "commands", words: []string{"c"}, expected: []string{"cache", "config", "completion"}},
+		{name: "subcommands", words: []string{"cache", "p"}, expected: []string{"prune"}},
+		{name: "flags", words: []string{"weekend", "--c"}, expected: []string{"--country"}},
+		{name: "global flags", words: []string{"--offline", "--cache-b"}, expected: []string{"--cache-backend"}},
+		{name: "countries from the cache", words: []string{"--offline", "weekend", "--country", ""}, expected: []string{"Belgium", "Monaco"}},
+		{name: "session types", words: []string{"get_session", "--type", "Sprint "}, expected: []string{"Sprint Qualifying", "Sprint Shootout"}},
+		{name: "cache keys", words: []string{"cache", "rm", "weekend:"}, expected: []string{"weekend:Belgium:2023"}},
+		{name: "config keys", words: []string{"config", "set", "cache.m"}, expected: []string{"cache.max_size", "cache.max_entries"}},
+		{name: "help", words: []string{"help", "cache", "w"}, expected: []string{"warm"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, code := runCapture(t, e, append([]string{"__complete"}, tt.words...)...)
+			if code != 0 {
+				t.Fatalf("expected exit code 0, got %d", code)
+			}
+			got := strings.Split(strings.TrimSpace(output), "\n")
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	_, e := newTestEnv(t)
+
+	output, code := runCapture(t, e, "--output", "json", "latest")
+	var latest struct {
+		Session struct {
+			SessionName string `json:"session_name"`
+			State       string `json:"state"`
+		} `json:"session"`
+		Warning string `json:"warning"`
+		Offline bool   `json:"offline"`
+	}
+	if err := json.Unmarshal([]byte(output), &latest); err != nil || code != 0 {
+		t.Fatalf("expected JSON, got %d %v:\n%s", code, err, output)
+	}
+	if latest.Session.SessionName != "Race" || latest.Session.State != "Live" {
+		t.Errorf("unexpected latest %+v", latest)
+	}
+
+	output, _ = runCapture(t, e, "--output", "csv", "weekend", "--country", "Belgium", "--year", "2023")
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil || len(records) != len(belgium2023)+1 || records[0][1] != "session_name" {
+		t.Errorf("expected a header and a row per session, got %v:\n%s", err, output)
+	}
+
+	output, _ = runCapture(t, e, "--output", "json", "weekend", "--country", "Belgium", "--year", "2023")
+	var weekend struct {
+		Meetings []struct {
+			MeetingName string `json:"meeting_name"`
+			Format      string `json:"format"`
+			Days        []struct {
+				Date string `json:"date"`
+			} `json:"days"`
+		} `json:"meetings"`
+	}
+	if err := json.Unmarshal([]byte(output), &weekend); err != nil || len(weekend.Meetings) != 1 {
+		t.Fatalf("expected one meeting, got %v:\n%s", err, output)
+	}
+	if m := weekend.Meetings[0]; m.MeetingName != "Belgian Grand Prix" || m.Format != "sprint" || len(m.Days) != 3 || m.Days[0].Date != "2023-07-28" {
+		t.Errorf("unexpected meeting %+v", m)
+	}
+
+	output, _ = runCapture(t, e, "--output", "ndjson", "cache", "info")
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) != 2 || !strings.Contains(output, `"key":"weekend:Belgium:2023"`) {
+		t.Errorf("expected one cache entry per line, got:\n%s", output)
+	}
+
+	output, code = runCapture(t, e, "--output", "yaml", "get_session", "--country", "Monaco", "--year", "2023")
+	if code != 1 || !strings.Contains(output, "session: null") {
+		t.Errorf("expected a null session and exit code 1, got %d:\n%s", code, output)
+	}
+
+	if _, code := runCapture(t, e, "--output", "xml", "latest"); code != 2 {
+		t.Errorf("expected exit code 2 for an unknown format, got %d", code)
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	_, e := newTestEnv(t)
+
+	tests := []struct {
+		name           string
+		args           []string
+		expectedCode   int
+		expectedOutput []string
+	}{
+		{
+			name:           "Default zone",
+			args:           []string{"get_session", "--country", "Belgium", "--year", "2023"},
+			expectedOutput: []string{"Starts: Sun, 30 Jul 2023 13:00:00 UTC"},
+		},
+		{
+			name:           "Chosen zone",
+			args:           []string{"--tz", "Asia/Tokyo", "get_session", "--country", "Belgium", "--year", "2023"},
+			expectedOutput: []string{"Starts: Sun, 30 Jul 2023 22:00:00 JST"},
+		},
+		{
+			name:           "Track time",
+			args:           []string{"--tz", "Asia/Tokyo", "--track-time", "latest"},
+			expectedOutput: []string{"Ends at: 17:00 UTC+2"},
+		},
+		{
+			name:           "Weekend in track time",
+			args:           []string{"--track-time", "weekend", "--country", "Belgium", "--year", "2023"},
+			expectedOutput: []string{"Race\t15:00 UTC+2", "Sprint\t17:05 UTC+2"},
+		},
+		{
+			name:           "Weekend days in chosen zone",
+			args:           []string{"--tz", "Pacific/Auckland", "weekend", "--country", "Belgium", "--year", "2023"},
+			expectedOutput: []string{"Sat 29 Jul\n\tQualifying\t03:00 NZST", "Mon 31 Jul\n\tRace\t01:00 NZST"},
+		},
+		{
+			name:           "Template localtime",
+			args:           []string{"--tz", "America/New_York", "--format", "{{.DateStart | localtime}}", "latest"},
+			expectedOutput: []string{"Sun 30 Jul 09:00 EDT"},
+		},
+		{
+			name:           "Unknown zone",
+			args:           []string{"--tz", "Mars/Olympus", "latest"},
+			expectedCode:   2,
+			expectedOutput: []string{"--tz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, code := runCapture(t, e, tt.args...)
+			if code != tt.expectedCode {
+				t.Errorf("expected exit code %d, got %d:\n%s", tt.expectedCode, code, output)
+			}
+			for _, expected := range tt.expectedOutput {
+				if !strings.Contains(output, expected) {
+					t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+				}
+			}
+		})
+	}
+
+	t.Run("Default zone", func(t *testing.T) {
+		e := e
+		e.loc = nil
+		output, _ := runCapture(t, e, "latest")
+		if !strings.Contains(output, "Ends at: 16:00 BST") {
+			t.Errorf("expected times in UK time by default, got:\n%s", output)
+		}
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		t.Setenv("PITWALL_TRACK_TIME", "0")
+		if output, _ := runCapture(t, e, "latest"); strings.Contains(output, "UTC+2") {
+			t.Errorf("expected PITWALL_TRACK_TIME=0 to leave track time off, got:\n%s", output)
+		}
+
+		t.Setenv("PITWALL_TRACK_TIME", "maybe")
+		if output, code := runCapture(t, e, "latest"); code != 2 || !strings.Contains(output, `invalid PITWALL_TRACK_TIME "maybe"`) {
+			t.Errorf("expected an invalid setting error, got %d:\n%s", code, output)
+		}
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		if output, code := runCapture(t, e, "config", "set", "timezone", "Nowhere/Special"); code != 2 || !strings.Contains(output, "unknown time zone") {
+			t.Errorf("expected an invalid zone to be rejected, got %d:\n%s", code, output)
+		}
+
+		runCapture(t, e, "config", "set", "timezone", "Europe/London")
+		output, _ := runCapture(t, e, "latest")
+		if !strings.Contains(output, "Ends at: 16:00 BST") {
+			t.Errorf("expected times in the configured zone, got:\n%s", output)
+		}
+
+		output, _ = runCapture(t, e, "--tz", "UTC", "latest")
+		if !strings.Contains(output, "Ends at: 15:00 UTC") {
+			t.Errorf("expected --tz to win over the config, got:\n%s", output)
+		}
+	})
+}
+
+func TestFormatTemplate(t *testing.T) {
+	_, e := newTestEnv(t)
+
+	output, code := runCapture(t, e, "--format", "{{.SessionName}} {{.State}} {{.DateEnd | relative}}", "latest")
+	if code != 0 || output != "Race Live in 1h 0m\n" {
+		t.Errorf("expected templated line, got %d %q", code, output)
+	}
+
+	output, _ = runCapture(t, e, "--format", "{{.SessionName}}", "weekend", "--country", "Belgium", "--year", "2023")
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) != len(belgium2023) {
+		t.Errorf("expected a line per session, got:\n%s", output)
+	}
+
+	if output, code := runCapture(t, e, "--format", "{{.Nope", "latest"); code != 2 || !strings.Contains(output, "--format") {
+		t.Errorf("expected a template error, got %d:\n%s", code, output)
+	}
+}
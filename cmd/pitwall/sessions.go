@@ -0,0 +1,273 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+	"github.com/bhopalg/pitwall/internal/output"
+	"github.com/bhopalg/pitwall/internal/services/getsession"
+	"github.com/bhopalg/pitwall/internal/services/latest"
+	"github.com/bhopalg/pitwall/internal/services/remind"
+	"github.com/bhopalg/pitwall/internal/services/weekend"
+	"github.com/bhopalg/pitwall/utils"
+)
+
+func latestCommand() *command {
+	return &command{
+		name:    "latest",
+		args:    "",
+		summary: "Show the live or next session",
+		help:    "Show the session running now, or the next one to start, with a countdown.",
+		run: func(a *app, args []string) int {
+			service := latest.New(a.client, a.cache, a.latestOpts...)
+			s, err := service.Next(a.ctx)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return exitError
+			}
+
+			result := output.SessionResult{Meta: output.NewMeta(s.Warning, s.Offline, s.CachedAt)}
+			if s.Session == nil {
+				return a.render(result, exitNoResult, func() {
+					fmt.Println("No session found.")
+				})
+			}
+			session := output.NewSession(*s.Session, a.now)
+			result.Session = &session
+
+			return a.render(result, exitOK, func() {
+				if s.Warning != "" {
+					fmt.Println(s.Warning)
+				}
+
+				fmt.Printf("%s - %s (%s)\n", s.Session.SessionName, s.Session.CircuitName, s.Session.CountryName)
+				utils.PrintSessionStatus(s.Session, a.now, a.zone(s.Session))
+			})
+		},
+	}
+}
+
+func getSessionCommand() *command {
+	var country, session_type, session_year string
+
+	return &command{
+		name:    "get_session",
+		args:    "[flags]",
+		summary: "Show one session of a race weekend",
+		help: "Show when a session of a race weekend starts. --country, --type and --year\n" +
+			"default to the country, session_type and year config settings.",
+		flags: func(fs *flag.FlagSet, a *app) {
+			fs.StringVar(&country, "country", a.profile.Country, "country name for session")
+			fs.StringVar(&session_type, "type", cmp.Or(a.profile.SessionType, "Race"), "session type e.g. Sprint, Race")
+			fs.StringVar(&session_year, "year", cmp.Or(a.profile.Year, strconv.Itoa(a.now.Year())), "session year")
+		},
+		run: func(a *app, args []string) int {
+			if country == "" {
+				return noCountry()
+			}
+
+			service := getsession.New(a.client, a.cache, a.getSessionOpts...)
+			s, err := service.GetSession(a.ctx, country, session_type, session_year)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return exitError
+			}
+
+			result := output.SessionResult{Meta: output.NewMeta(s.Warning, s.Offline, s.CachedAt)}
+			if s.Session == nil {
+				return a.render(result, exitNoResult, func() {
+					fmt.Println("No sessions found.")
+				})
+			}
+			session := output.NewSession(*s.Session, a.now)
+			result.Session = &session
+
+			return a.render(result, exitOK, func() {
+				if s.Warning != "" {
+					fmt.Println(s.Warning)
+				}
+
+				fmt.Printf("%s - %s (%s)\n", s.Session.SessionName, s.Session.CircuitName, s.Session.CountryName)
+				fmt.Printf("Starts: %s\n", s.Session.DateStart.In(a.zone(s.Session)).Format(time.RFC1123))
+			})
+		},
+	}
+}
+
+func weekendCommand() *command {
+	var country, session_year string
+
+	return &command{
+		name:    "weekend",
+		args:    "[flags]",
+		summary: "Show every session of a race weekend",
+		help: "List the sessions of a race weekend by day, including testing, with each\n" +
+			"session's state and countdown. --country and --year default to the country\n" +
+			"and year config settings.",
+		flags: func(fs *flag.FlagSet, a *app) {
+			fs.StringVar(&country, "country", a.profile.Country, "country name for session")
+			fs.StringVar(&session_year, "year", cmp.Or(a.profile.Year, strconv.Itoa(a.now.Year())), "session year")
+		},
+		run: func(a *app, args []string) int {
+			if country == "" {
+				return noCountry()
+			}
+
+			service := weekend.New(a.client, a.cache, a.weekendOpts...)
+			sessions, err := service.Weekend(a.ctx, country, session_year)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return exitError
+			}
+
+			result := output.WeekendResult{
+				CountryName: country,
+				Year:        session_year,
+				Sessions:    []output.Session{},
+				Meetings:    []output.WeekendMeeting{},
+				Meta:        output.NewMeta(sessions.Warning, sessions.Offline, sessions.CachedAt),
+			}
+			if len(sessions.Meetings) == 0 {
+				return a.render(result, exitNoResult, func() {
+					fmt.Println("No sessions found.")
+				})
+			}
+
+			for _, m := range sessions.Meetings {
+				meeting := output.WeekendMeeting{MeetingKey: m.MeetingKey, MeetingName: m.Name, Format: string(m.Format)}
+				for _, day := range m.Days {
+					weekendDay := output.WeekendDay{Date: day.Date.Format(time.DateOnly)}
+					for _, s := range day.Sessions {
+						result.Sessions = append(result.Sessions, output.NewSession(s, a.now))
+						weekendDay.SessionKeys = append(weekendDay.SessionKeys, s.SessionKey)
+					}
+					meeting.Days = append(meeting.Days, weekendDay)
+				}
+				result.Meetings = append(result.Meetings, meeting)
+			}
+
+			return a.render(result, exitOK, func() {
+				if sessions.Warning != "" {
+					fmt.Println(sessions.Warning)
+				}
+
+				for _, m := range sessions.Meetings {
+					first := m.Days[0].Sessions[0]
+					name := m.Name
+					if name == "" {
+						name = first.CountryName + " Grand Prix"
+					}
+					fmt.Printf("%s - %s\n", name, first.CircuitName)
+					fmt.Printf("Format: %s\n\n", m.Format)
+
+					for _, day := range m.Days {
+						fmt.Println(day.Date.Format("Mon 02 Jan"))
+						for _, session := range day.Sessions {
+							fmt.Printf("\t%s\t%s\t%s\t%s\n",
+								session.SessionName,
+								session.DateStart.In(a.zone(&session)).Format("15:04 MST"),
+								session.State(a.now),
+								countdown(&session, a.now),
+							)
+						}
+						fmt.Println()
+					}
+				}
+			})
+		},
+	}
+}
+
+func remindCommand() *command {
+	var (
+		threshold int
+		quiet     bool
+	)
+
+	return &command{
+		name:    "remind",
+		args:    "[flags]",
+		summary: "Check whether the next session starts soon",
+		help: "Exit 0 and print a reminder if the next session starts within --minutes,\n" +
+			"otherwise exit 1. Handy in cron jobs and shell prompts.",
+		flags: func(fs *flag.FlagSet, a *app) {
+			fs.IntVar(&threshold, "minutes", cmp.Or(a.profile.RemindMinutes, 30), "minutes threshold for reminder")
+			fs.BoolVar(&quiet, "quiet", false, "suppress output if no reminder")
+		},
+		run: func(a *app, args []string) int {
+			service := latest.New(a.client, a.cache, a.latestOpts...)
+			res, err := service.Next(a.ctx)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return exitError
+			}
+
+			result := output.RemindResult{
+				ThresholdMinutes: threshold,
+				Meta:             output.NewMeta(res.Warning, res.Offline, res.CachedAt),
+			}
+			if res.Session == nil {
+				return a.render(result, exitNoResult, func() {
+					if !quiet {
+						fmt.Println("No upcoming sessions found.")
+					}
+				})
+			}
+
+			trigger, diff := remind.ShouldRemind(a.now, res.Session.DateStart, threshold)
+
+			session := output.NewSession(*res.Session, a.now)
+			result.Session = &session
+			result.Remind = trigger
+			result.StartsInSeconds = int64(diff / time.Second)
+
+			code := exitNoResult
+			if trigger {
+				code = exitOK
+			}
+
+			return a.render(result, code, func() {
+				if res.Warning != "" && !quiet {
+					fmt.Println(res.Warning)
+				}
+
+				startsAt := res.Session.DateStart.In(a.zone(res.Session)).Format("15:04 MST")
+				if trigger {
+					fmt.Printf("REMIND: %s starts in %v, at %s!\n", res.Session.SessionName, diff.Round(time.Minute), startsAt)
+					return
+				}
+
+				if !quiet {
+					fmt.Printf("No reminder needed. Next session (%s) is in %v, at %s.\n",
+						res.Session.SessionName, diff.Round(time.Minute), startsAt)
+				}
+			})
+		},
+	}
+}
+
+func noCountry() int {
+	fmt.Fprintln(os.Stderr, "error: no country given; pass --country or run: pitwall config set country <name>")
+	return exitError
+}
+
+// countdown says how long until a session starts or ends, or how long ago
+// it ended.
+func countdown(s *domain.Session, now time.Time) string {
+	switch s.State(now) {
+	case domain.StateFuture:
+		return "starts in " + utils.FormatDuration(s.DateStart.Sub(now).Round(time.Minute))
+	case domain.StateLive:
+		if s.DateEnd.IsZero() {
+			return "end TBD"
+		}
+		return "ends in " + utils.FormatDuration(s.DateEnd.Sub(now).Round(time.Minute))
+	default:
+		return "ended " + utils.FormatDuration(now.Sub(s.DateEnd).Round(time.Minute)) + " ago"
+	}
+}
@@ -21,6 +21,12 @@ type Session struct {
 	MeetingKey   int
 	Year         int
 	SessionState SessionState
+	// GMTOffset is the circuit's offset from UTC during the meeting, nil
+	// when unknown, e.g. for sessions cached before it was recorded.
+	GMTOffset *time.Duration
+	// MeetingName is e.g. "Belgian Grand Prix". Only the weekend service
+	// looks it up, so it is empty elsewhere.
+	MeetingName string
 }
 
 func (s *Session) State(now time.Time) SessionState {
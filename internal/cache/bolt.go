@@ -0,0 +1,566 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltFile is the database file name BoltCache uses inside a cache
+// directory.
+const BoltFile = "pitwall.db"
+
+var (
+	boltEntries = []byte("entries")
+	boltMeta    = []byte("meta")
+)
+
+// BoltCache is a Cache stored in a single embedded database file. Entries
+// are kept in the same encoded form FileCache writes, and their timestamps
+// and sizes in a separate bucket so Info never decodes payloads.
+//
+// The database is opened for each operation and closed again before it
+// returns, read-only for reads, so processes sharing it only wait on each
+// other for the length of one write, up to LockTimeout.
+type BoltCache struct {
+	Path string
+	// MaxBytes and MaxEntries limit the cache as they do for FileCache.
+	MaxBytes   int64
+	MaxEntries int
+	// CompressAbove works as it does for FileCache.
+	CompressAbove int
+	LockTimeout   time.Duration
+}
+
+// boltMetaRecord is what the meta bucket holds for each key.
+type boltMetaRecord struct {
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	AccessedAt time.Time `json:"accessed_at"`
+	Size       int64     `json:"size"`
+	RawSize    int64     `json:"raw_size"`
+}
+
+func NewBoltCache(path string) *BoltCache {
+	return &BoltCache{Path: path, LockTimeout: 5 * time.Second}
+}
+
+// open opens the database for a single operation; the caller must close it.
+// A read-only open shares the file lock with other readers.
+func (b *BoltCache) open(readOnly bool) (*bolt.DB, error) {
+	if readOnly {
+		db, err := bolt.Open(b.Path, 0644, &bolt.Options{Timeout: b.LockTimeout, ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		err = db.View(func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{boltEntries, boltMeta} {
+				if tx.Bucket(name) == nil {
+					return fmt.Errorf("missing bucket %q", name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return db, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(b.Path, 0644, &bolt.Options{Timeout: b.LockTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltEntries, boltMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// exists reports whether the database file has been created, so read-only
+// commands on an empty cache do not create it.
+func (b *BoltCache) exists() bool {
+	_, err := os.Stat(b.Path)
+	return err == nil
+}
+
+func (b *BoltCache) limited() bool {
+	return b.MaxBytes > 0 || b.MaxEntries > 0
+}
+
+func (b *BoltCache) Get(key string, target interface{}) (bool, bool, error) {
+	meta, found, err := b.GetWithMeta(key, target)
+	return found, found && meta.IsStale(time.Now()), err
+}
+
+func (b *BoltCache) GetWithMeta(key string, target interface{}) (Meta, bool, error) {
+	if !b.exists() {
+		return Meta{}, false, nil
+	}
+
+	db, err := b.open(true)
+	if err != nil {
+		return Meta{}, false, newError("get", key, b.Path, err)
+	}
+
+	var data []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		// Values are only valid inside the transaction.
+		data = bytes.Clone(tx.Bucket(boltEntries).Get([]byte(key)))
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		return Meta{}, false, newError("get", key, b.Path, err)
+	}
+	if data == nil {
+		return Meta{}, false, nil
+	}
+
+	entry, err := parseEntry(data)
+	if err != nil {
+		return Meta{}, false, &Error{Op: "get", Key: key, Path: b.Path, Kind: ErrCorrupt, Err: err}
+	}
+
+	migrated, stale, err := decodeEntry(key, entry, target)
+	if err != nil {
+		return Meta{}, false, withPath(err, "get", key, b.Path)
+	}
+	if stale {
+		entry = expire(entry)
+	}
+
+	switch {
+	case migrated:
+		b.rewrite(key, entry, target)
+	case b.limited():
+		// Access times only matter for eviction, so reads stay read-only
+		// without limits.
+		b.touch(key)
+	}
+
+	return Meta{CreatedAt: entry.CreatedAt, ExpiresAt: entry.ExpiresAt}, true, nil
+}
+
+// rewrite stores a migrated entry in its current form, keeping its original
+// timestamps.
+func (b *BoltCache) rewrite(key string, old rawEntry, target interface{}) {
+	entry, err := newEntry(key, reflect.ValueOf(target).Elem().Interface(), 0)
+	if err != nil {
+		return
+	}
+	entry.CreatedAt = old.CreatedAt
+	entry.ExpiresAt = old.ExpiresAt
+	_ = b.put(key, entry)
+}
+
+// touch records that key was read. Failures are ignored.
+func (b *BoltCache) touch(key string) {
+	db, err := b.open(false)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	_ = db.Update(func(tx *bolt.Tx) error {
+		return touchMeta(tx, key)
+	})
+}
+
+func (b *BoltCache) Set(key string, value interface{}, ttl time.Duration) error {
+	entry, err := newEntry(key, value, ttl)
+	if err != nil {
+		return err
+	}
+	return b.put(key, entry)
+}
+
+func (b *BoltCache) put(key string, entry CacheEntry) error {
+	data, err := encodeEntry(entry, compressThreshold(b.CompressAbove))
+	if err != nil {
+		return &Error{Op: "set", Key: key, Err: err}
+	}
+
+	rawSize := int64(len(data))
+	if parsed, err := parseHeader(data); err == nil {
+		rawSize = parsed.rawSize()
+	}
+
+	meta, err := json.Marshal(boltMetaRecord{
+		CreatedAt:  entry.CreatedAt,
+		ExpiresAt:  entry.ExpiresAt,
+		AccessedAt: time.Now(),
+		Size:       int64(len(data)),
+		RawSize:    rawSize,
+	})
+	if err != nil {
+		return &Error{Op: "set", Key: key, Err: err}
+	}
+
+	db, err := b.open(false)
+	if err != nil {
+		return newError("set", key, b.Path, err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltEntries).Put([]byte(key), data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltMeta).Put([]byte(key), meta); err != nil {
+			return err
+		}
+		if !b.limited() {
+			return nil
+		}
+
+		entries, err := boltInfo(tx, nil)
+		if err != nil {
+			return err
+		}
+		return boltDelete(tx, overLimit(entries, b.MaxBytes, b.MaxEntries, key))
+	})
+	if err != nil {
+		return newError("set", key, b.Path, err)
+	}
+	return nil
+}
+
+func (b *BoltCache) Clear() (int, error) {
+	if !b.exists() {
+		return 0, nil
+	}
+
+	db, err := b.open(false)
+	if err != nil {
+		return 0, newError("clear", "", b.Path, err)
+	}
+	defer db.Close()
+
+	count := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		count = tx.Bucket(boltEntries).Stats().KeyN
+		for _, name := range [][]byte{boltEntries, boltMeta} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, newError("clear", "", b.Path, err)
+	}
+	return count, nil
+}
+
+func (b *BoltCache) Info() ([]InfoEntry, string, error) {
+	absPath, _ := filepath.Abs(b.Path)
+	if !b.exists() {
+		return nil, absPath, nil
+	}
+
+	db, err := b.open(true)
+	if err != nil {
+		return nil, absPath, newError("info", "", absPath, err)
+	}
+	defer db.Close()
+
+	var infos []InfoEntry
+	err = db.View(func(tx *bolt.Tx) error {
+		var err error
+		infos, err = boltInfo(tx, nil)
+		return err
+	})
+	if err != nil {
+		return nil, absPath, newError("info", "", absPath, err)
+	}
+	return infos, absPath, nil
+}
+
+func (b *BoltCache) Remove(key string) (bool, error) {
+	if !b.exists() {
+		return false, nil
+	}
+
+	db, err := b.open(false)
+	if err != nil {
+		return false, newError("remove", key, b.Path, err)
+	}
+	defer db.Close()
+
+	removed := false
+	err = db.Update(func(tx *bolt.Tx) error {
+		removed = tx.Bucket(boltEntries).Get([]byte(key)) != nil
+		return boltDelete(tx, []InfoEntry{{Key: key}})
+	})
+	if err != nil {
+		return false, newError("remove", key, b.Path, err)
+	}
+	return removed, nil
+}
+
+func (b *BoltCache) Prune(filter PruneFilter) ([]string, error) {
+	if !b.exists() {
+		return nil, nil
+	}
+
+	db, err := b.open(false)
+	if err != nil {
+		return nil, newError("prune", "", b.Path, err)
+	}
+	defer db.Close()
+
+	var removed []string
+	err = db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+
+		var remove []InfoEntry
+		if !filter.empty() {
+			candidates, err := boltInfo(tx, []byte(filter.Prefix))
+			if err != nil {
+				return err
+			}
+			for _, e := range candidates {
+				if filter.matches(e, now) {
+					remove = append(remove, e)
+				}
+			}
+		}
+		if err := boltDelete(tx, remove); err != nil {
+			return err
+		}
+
+		if b.limited() {
+			rest, err := boltInfo(tx, nil)
+			if err != nil {
+				return err
+			}
+			evict := overLimit(rest, b.MaxBytes, b.MaxEntries, "")
+			if err := boltDelete(tx, evict); err != nil {
+				return err
+			}
+			remove = append(remove, evict...)
+		}
+
+		for _, e := range remove {
+			removed = append(removed, e.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, newError("prune", "", b.Path, err)
+	}
+	return removed, nil
+}
+
+// Verify reports entries that do not decode or do not match their codec,
+// and entries whose metadata is missing.
+func (b *BoltCache) Verify() ([]*Error, error) {
+	if !b.exists() {
+		return nil, nil
+	}
+
+	db, err := b.open(true)
+	if err != nil {
+		return nil, newError("verify", "", b.Path, err)
+	}
+	defer db.Close()
+
+	var problems []*Error
+	err = db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMeta)
+		err := tx.Bucket(boltEntries).ForEach(func(k, v []byte) error {
+			key := string(k)
+			entry, err := parseEntry(v)
+			if err != nil {
+				problems = append(problems, &Error{Op: "verify", Key: key, Path: b.Path, Kind: ErrCorrupt, Err: err})
+				return nil
+			}
+			if err := checkEntry("verify", key, entry); err != nil {
+				problems = append(problems, withPath(err, "verify", key, b.Path))
+				return nil
+			}
+			if meta.Get(k) == nil {
+				problems = append(problems, &Error{Op: "verify", Key: key, Path: b.Path, Err: errors.New("entry is missing its metadata")})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		entries := tx.Bucket(boltEntries)
+		return meta.ForEach(func(k, v []byte) error {
+			if entries.Get(k) == nil {
+				problems = append(problems, &Error{Op: "verify", Key: string(k), Path: b.Path, Err: errors.New("metadata has no entry")})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return problems, newError("verify", "", b.Path, err)
+	}
+	return problems, nil
+}
+
+func (b *BoltCache) Export(match func(key string) bool) ([]Record, error) {
+	if !b.exists() {
+		return nil, nil
+	}
+
+	db, err := b.open(true)
+	if err != nil {
+		return nil, newError("export", "", b.Path, err)
+	}
+	defer db.Close()
+
+	var records []Record
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltEntries).ForEach(func(k, v []byte) error {
+			if key := string(k); match(key) && validEntry(v) {
+				records = append(records, Record{Key: key, Data: bytes.Clone(v)})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, newError("export", "", b.Path, err)
+	}
+	return records, nil
+}
+
+func (b *BoltCache) Import(records []Record) (int, error) {
+	for _, r := range records {
+		if err := checkRecord(r); err != nil {
+			return 0, err
+		}
+	}
+
+	db, err := b.open(false)
+	if err != nil {
+		return 0, newError("import", "", b.Path, err)
+	}
+	defer db.Close()
+
+	imported := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, r := range records {
+			if keepExisting(tx.Bucket(boltEntries).Get([]byte(r.Key)), r.Data) {
+				continue
+			}
+
+			entry, err := parseHeader(r.Data)
+			if err != nil {
+				return &Error{Op: "import", Key: r.Key, Kind: ErrCorrupt, Err: err}
+			}
+			meta, err := json.Marshal(boltMetaRecord{
+				CreatedAt:  entry.CreatedAt,
+				ExpiresAt:  entry.ExpiresAt,
+				AccessedAt: time.Now(),
+				Size:       int64(len(r.Data)),
+				RawSize:    entry.rawSize(),
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Bucket(boltEntries).Put([]byte(r.Key), r.Data); err != nil {
+				return err
+			}
+			if err := tx.Bucket(boltMeta).Put([]byte(r.Key), meta); err != nil {
+				return err
+			}
+			imported++
+		}
+
+		if !b.limited() {
+			return nil
+		}
+		entries, err := boltInfo(tx, nil)
+		if err != nil {
+			return err
+		}
+		return boltDelete(tx, overLimit(entries, b.MaxBytes, b.MaxEntries, ""))
+	})
+	if err != nil {
+		return 0, newError("import", "", b.Path, err)
+	}
+	return imported, nil
+}
+
+// boltInfo lists the entries whose keys start with prefix using a cursor
+// seek, so only matching keys are visited.
+func boltInfo(tx *bolt.Tx, prefix []byte) ([]InfoEntry, error) {
+	now := time.Now()
+
+	var infos []InfoEntry
+	c := tx.Bucket(boltMeta).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var m boltMetaRecord
+		if err := json.Unmarshal(v, &m); err != nil {
+			return nil, &Error{Op: "info", Key: string(k), Kind: ErrCorrupt, Err: err}
+		}
+		infos = append(infos, InfoEntry{
+			Key:        string(k),
+			CreatedAt:  m.CreatedAt,
+			ExpiresAt:  m.ExpiresAt,
+			IsStale:    now.After(m.ExpiresAt),
+			AccessedAt: m.AccessedAt,
+			Size:       m.Size,
+			RawSize:    m.RawSize,
+		})
+	}
+	return infos, nil
+}
+
+func boltDelete(tx *bolt.Tx, entries []InfoEntry) error {
+	for _, e := range entries {
+		for _, name := range [][]byte{boltEntries, boltMeta} {
+			if err := tx.Bucket(name).Delete([]byte(e.Key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func touchMeta(tx *bolt.Tx, key string) error {
+	bucket := tx.Bucket(boltMeta)
+
+	var m boltMetaRecord
+	if err := json.Unmarshal(bucket.Get([]byte(key)), &m); err != nil {
+		return err
+	}
+	m.AccessedAt = time.Now()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), data)
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBolt(t *testing.T) *BoltCache {
+	t.Helper()
+
+	return NewBoltCache(filepath.Join(t.TempDir(), BoltFile))
+}
+
+func TestBoltCache(t *testing.T) {
+	b := newTestBolt(t)
+
+	t.Run("Get on a missing database is a miss", func(t *testing.T) {
+		var v string
+		found, _, err := b.Get("weekend:Belgium:2023", &v)
+		if err != nil || found {
+			t.Fatalf("expected a miss, got found=%v err=%v", found, err)
+		}
+		if b.exists() {
+			t.Error("expected Get not to create the database")
+		}
+	})
+
+	t.Run("Set and Get", func(t *testing.T) {
+		if err := b.Set("weekend:Belgium:2023", "fresh", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Set("weekend:Monaco:2023", "stale", -time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		var v string
+		found, isStale, err := b.Get("weekend:Belgium:2023", &v)
+		if err != nil || !found || isStale || v != "fresh" {
+			t.Errorf("unexpected result %q found=%v stale=%v err=%v", v, found, isStale, err)
+		}
+
+		found, isStale, _ = b.Get("weekend:Monaco:2023", &v)
+		if !found || !isStale {
+			t.Errorf("expected stale entry, got found=%v stale=%v", found, isStale)
+		}
+	})
+
+	t.Run("Large entries are compressed", func(t *testing.T) {
+		large := strings.Repeat("lap ", 5000)
+		if err := b.Set("laps:9141", large, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		var v string
+		if found, _, _ := b.Get("laps:9141", &v); !found || v != large {
+			t.Error("expected large value to round trip")
+		}
+
+		entries, _, _ := b.Info()
+		for _, e := range entries {
+			if e.Key == "laps:9141" && e.RawSize <= e.Size {
+				t.Errorf("expected raw size %d to exceed stored size %d", e.RawSize, e.Size)
+			}
+		}
+	})
+
+	t.Run("Persists across instances", func(t *testing.T) {
+		var v string
+		if found, _, _ := NewBoltCache(b.Path).Get("weekend:Belgium:2023", &v); !found {
+			t.Error("expected entry from a new instance")
+		}
+	})
+
+	t.Run("Database is not held between operations", func(t *testing.T) {
+		// A second instance opens the file separately, like another
+		// process does, so it would time out if b still held the lock.
+		other := NewBoltCache(b.Path)
+		other.LockTimeout = 100 * time.Millisecond
+
+		var v string
+		_, _, _ = b.Get("weekend:Belgium:2023", &v)
+		if err := other.Set("weekend:Belgium:2023", "fresh", time.Hour); err != nil {
+			t.Fatalf("expected the write to get the lock, got %v", err)
+		}
+		_ = b.Set("weekend:Belgium:2023", "fresh", time.Hour)
+		if _, _, err := other.Get("weekend:Belgium:2023", &v); err != nil {
+			t.Fatalf("expected the read to get the lock, got %v", err)
+		}
+	})
+
+	t.Run("Prune by prefix", func(t *testing.T) {
+		removed, err := b.Prune(PruneFilter{Stale: true, Prefix: "weekend:"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(removed, []string{"weekend:Monaco:2023"}) {
+			t.Errorf("expected only the stale weekend pruned, got %v", removed)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		removed, err := b.Remove("laps:9141")
+		if err != nil || !removed {
+			t.Fatalf("expected entry removed, got %v, %v", removed, err)
+		}
+		if removed, _ := b.Remove("laps:9141"); removed {
+			t.Error("expected second remove to find nothing")
+		}
+	})
+
+	t.Run("Verify and Clear", func(t *testing.T) {
+		problems, err := b.Verify()
+		if err != nil || len(problems) != 0 {
+			t.Fatalf("expected a healthy cache, got %v, %v", problems, err)
+		}
+
+		count, err := b.Clear()
+		if err != nil || count != 1 {
+			t.Fatalf("expected 1 entry cleared, got %d, %v", count, err)
+		}
+		entries, _, _ := b.Info()
+		if len(entries) != 0 {
+			t.Errorf("expected empty cache, got %v", entries)
+		}
+	})
+}
+
+func TestBoltCacheEviction(t *testing.T) {
+	b := newTestBolt(t)
+	b.MaxEntries = 2
+
+	_ = b.Set("a", "data", time.Hour)
+	time.Sleep(time.Millisecond)
+	_ = b.Set("b", "data", time.Hour)
+	time.Sleep(time.Millisecond)
+
+	var v string
+	_, _, _ = b.Get("a", &v)
+	_ = b.Set("c", "data", time.Hour)
+
+	if found, _, _ := b.Get("b", &v); found {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if found, _, _ := b.Get(key, &v); !found {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+}
+
+func TestBoltCacheCodecs(t *testing.T) {
+	b := newTestBolt(t)
+
+	if err := b.Set("codec-test:laps:1", []lapV2{{Number: 1}}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	var wrong string
+	_, _, err := b.Get("codec-test:laps:1", &wrong)
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Errorf("expected ErrSchemaMismatch, got %v", err)
+	}
+}
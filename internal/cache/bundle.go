@@ -0,0 +1,348 @@
+package cache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Record is an entry in its stored form, as moved between caches by export
+// and import. Data is exactly what the cache keeps, so it carries the
+// entry's timestamps, type tag and compression with it.
+type Record struct {
+	Key  string
+	Data []byte
+}
+
+// Exporter is implemented by caches whose entries can be copied out and
+// into another cache.
+type Exporter interface {
+	// Export returns the entries whose keys match.
+	Export(match func(key string) bool) ([]Record, error)
+	// Import stores records, keeping any existing entry that was created
+	// after the imported one. Nothing is written if any record cannot be
+	// read under its key. It returns how many records were written.
+	Import(records []Record) (int, error)
+}
+
+// BundleVersion is the manifest format written by WriteBundle.
+const BundleVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	entriesDir   = "entries"
+
+	// maxBundleFiles bounds how many files ReadBundle reads.
+	maxBundleFiles = 100_000
+)
+
+// maxBundleSize bounds the total uncompressed size of the files in a bundle,
+// so a damaged or hostile bundle cannot exhaust memory.
+var maxBundleSize int64 = 1 << 30
+
+// Manifest describes the entries in a bundle.
+type Manifest struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+type ManifestEntry struct {
+	Key       string    `json:"key"`
+	File      string    `json:"file"`
+	Type      string    `json:"type,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+}
+
+// WriteBundle writes records to w as a gzipped tar archive holding a
+// manifest with a checksum for every entry.
+func WriteBundle(w io.Writer, records []Record) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	manifest := Manifest{Version: BundleVersion, CreatedAt: time.Now().UTC()}
+	for _, r := range records {
+		entry, err := parseHeader(r.Data)
+		if err != nil {
+			return &Error{Op: "export", Key: r.Key, Kind: ErrCorrupt, Err: err}
+		}
+
+		sum := sha256.Sum256(r.Data)
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			Key:       r.Key,
+			File:      path.Join(entriesDir, entryFileName(r.Key, r.Data)),
+			Type:      entry.Type,
+			CreatedAt: entry.CreatedAt,
+			ExpiresAt: entry.ExpiresAt,
+			Size:      int64(len(r.Data)),
+			SHA256:    hex.EncodeToString(sum[:]),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, manifestName, data, manifest.CreatedAt); err != nil {
+		return err
+	}
+
+	for i, r := range records {
+		if err := writeTarFile(tw, manifest.Entries[i].File, r.Data, manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// ReadBundle reads a bundle written by WriteBundle, checking every entry
+// against the manifest, which must be the first file. Files the manifest
+// does not list are skipped. Nothing is returned unless the whole bundle is
+// intact and every entry can be read under its key.
+func ReadBundle(r io.Reader) ([]Record, Manifest, error) {
+	var manifest Manifest
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, manifest, fmt.Errorf("bundle: %w", err)
+	}
+	defer zr.Close()
+
+	var (
+		files  = make(map[string][]byte)
+		listed map[string]bool
+		count  int
+		size   int64
+	)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, manifest, fmt.Errorf("bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		count++
+		size += hdr.Size
+		if count > maxBundleFiles || size > maxBundleSize {
+			return nil, manifest, fmt.Errorf("bundle: larger than %d files or %d bytes", maxBundleFiles, maxBundleSize)
+		}
+
+		if listed == nil {
+			if hdr.Name != manifestName {
+				return nil, manifest, errors.New("bundle: " + manifestName + " must be the first file")
+			}
+			if listed, err = readManifest(tr, &manifest); err != nil {
+				return nil, manifest, err
+			}
+			continue
+		}
+
+		if !listed[hdr.Name] {
+			continue
+		}
+		if _, ok := files[hdr.Name]; ok {
+			return nil, manifest, fmt.Errorf("bundle: %s: duplicate file", hdr.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, manifest, fmt.Errorf("bundle: %s: %w", hdr.Name, err)
+		}
+		files[hdr.Name] = data
+	}
+
+	if listed == nil {
+		return nil, manifest, errors.New("bundle: missing " + manifestName)
+	}
+
+	records := make([]Record, 0, len(manifest.Entries))
+	for _, e := range manifest.Entries {
+		data, ok := files[e.File]
+		if !ok {
+			return nil, manifest, fmt.Errorf("bundle: %s: missing from archive", e.Key)
+		}
+
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != e.SHA256 {
+			return nil, manifest, fmt.Errorf("bundle: %s: checksum mismatch", e.Key)
+		}
+
+		r := Record{Key: e.Key, Data: data}
+		if err := checkRecord(r); err != nil {
+			return nil, manifest, err
+		}
+		records = append(records, r)
+	}
+	return records, manifest, nil
+}
+
+// readManifest decodes a bundle's manifest into manifest and returns the
+// files it lists.
+func readManifest(r io.Reader, manifest *Manifest) (map[string]bool, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %s: %w", manifestName, err)
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("bundle: %s: %w", manifestName, err)
+	}
+	if manifest.Version != BundleVersion {
+		return nil, fmt.Errorf("bundle: unsupported version %d", manifest.Version)
+	}
+
+	listed := make(map[string]bool, len(manifest.Entries))
+	for _, e := range manifest.Entries {
+		if listed[e.File] {
+			return nil, fmt.Errorf("bundle: %s: listed twice", e.File)
+		}
+		listed[e.File] = true
+	}
+	return listed, nil
+}
+
+// checkRecord reports whether r can be read under its key.
+func checkRecord(r Record) error {
+	entry, err := parseEntry(r.Data)
+	if err != nil {
+		return &Error{Op: "import", Key: r.Key, Kind: ErrCorrupt, Err: err}
+	}
+	return checkEntry("import", r.Key, entry)
+}
+
+// keepExisting reports whether a stored entry was created after the
+// imported one and should win.
+func keepExisting(existing, imported []byte) bool {
+	if existing == nil {
+		return false
+	}
+	old, err := parseHeader(existing)
+	if err != nil {
+		return false
+	}
+	e, err := parseHeader(imported)
+	return err == nil && old.CreatedAt.After(e.CreatedAt)
+}
+
+func (f *FileCache) Export(match func(key string) bool) ([]Record, error) {
+	if _, err := os.Stat(f.Dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	f.ensureMigrated()
+
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, newError("export", "", f.Dir, err)
+	}
+	defer unlock()
+
+	entries, err := f.entriesLocked()
+	if err != nil {
+		return nil, newError("export", "", f.Dir, err)
+	}
+
+	var records []Record
+	for _, e := range entries {
+		if e.Key == "" || !match(e.Key) {
+			continue
+		}
+		path := filepath.Join(f.Dir, e.File)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, newError("export", e.Key, path, err)
+		}
+		if !validEntry(data) {
+			continue
+		}
+		records = append(records, Record{Key: e.Key, Data: data})
+	}
+	return records, nil
+}
+
+func (f *FileCache) Import(records []Record) (int, error) {
+	f.ensureMigrated()
+
+	for _, r := range records {
+		if err := checkRecord(r); err != nil {
+			return 0, err
+		}
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return 0, newError("import", "", f.Dir, err)
+	}
+	defer unlock()
+
+	// Only written entries are indexed, including when a write fails part
+	// way through.
+	written := make(map[string]string)
+	var writeErr error
+	for _, r := range records {
+		_, existing, _ := f.readEntryFile(r.Key)
+		if keepExisting(existing, r.Data) {
+			continue
+		}
+		name, err := f.writeEntryFile(r.Key, r.Data)
+		if err != nil {
+			writeErr = newError("import", r.Key, filepath.Join(f.Dir, name), err)
+			break
+		}
+		written[name] = r.Key
+	}
+	imported := len(written)
+
+	err = f.updateIndex(func(index map[string]string) bool {
+		for name, key := range written {
+			indexEntry(index, key, name)
+		}
+		return imported > 0
+	})
+	if err != nil {
+		return imported, newError("import", "", filepath.Join(f.Dir, indexFile), err)
+	}
+	if writeErr != nil {
+		return imported, writeErr
+	}
+
+	if err := f.evictLocked(""); err != nil {
+		return imported, newError("import", "", f.Dir, err)
+	}
+	return imported, nil
+}
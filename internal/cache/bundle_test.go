@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	src := &FileCache{Dir: t.TempDir()}
+	_ = src.Set("weekend:Belgium:2023", "spa", time.Hour)
+	_ = src.Set("weekend:Monaco:2024", "monaco", time.Hour)
+	_ = src.Set("laps:2023", strings.Repeat("lap ", 5000), time.Hour)
+
+	records, err := src.Export(func(key string) bool { return strings.HasSuffix(key, "2023") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+
+	read, manifest, err := ReadBundle(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Version != BundleVersion || len(manifest.Entries) != 2 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	dst := NewBoltCache(filepath.Join(t.TempDir(), BoltFile))
+
+	n, err := dst.Import(read)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 imported, got %d, %v", n, err)
+	}
+
+	var v string
+	if found, _, _ := dst.Get("weekend:Belgium:2023", &v); !found || v != "spa" {
+		t.Errorf("expected imported entry, got %q", v)
+	}
+	if found, _, _ := dst.Get("laps:2023", &v); !found || !strings.HasPrefix(v, "lap ") {
+		t.Error("expected compressed entry to import")
+	}
+	if found, _, _ := dst.Get("weekend:Monaco:2024", &v); found {
+		t.Error("expected unmatched entry to be left out")
+	}
+}
+
+func TestBundleImportKeepsNewerEntries(t *testing.T) {
+	src := &FileCache{Dir: t.TempDir()}
+	_ = src.Set("weekend:Belgium:2023", "old", time.Hour)
+	records, _ := src.Export(func(string) bool { return true })
+
+	dst := &FileCache{Dir: t.TempDir()}
+	_ = dst.Set("weekend:Belgium:2023", "new", time.Hour)
+
+	n, err := dst.Import(records)
+	if err != nil || n != 0 {
+		t.Fatalf("expected nothing imported, got %d, %v", n, err)
+	}
+
+	var v string
+	_, _, _ = dst.Get("weekend:Belgium:2023", &v)
+	if v != "new" {
+		t.Errorf("expected newer entry to be kept, got %q", v)
+	}
+}
+
+func TestReadBundleChecksum(t *testing.T) {
+	src := &FileCache{Dir: t.TempDir()}
+	_ = src.Set("weekend:Belgium:2023", "spa", time.Hour)
+	records, _ := src.Export(func(string) bool { return true })
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := rewriteBundle(t, buf.Bytes(), func(name string, data []byte) []byte {
+		if strings.HasPrefix(name, entriesDir+"/") {
+			return bytes.Replace(data, []byte("spa"), []byte("spy"), 1)
+		}
+		return data
+	})
+
+	if _, _, err := ReadBundle(bytes.NewReader(tampered)); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestReadBundleLimits(t *testing.T) {
+	src := &FileCache{Dir: t.TempDir()}
+	_ = src.Set("weekend:Belgium:2023", "spa", time.Hour)
+	records, _ := src.Export(func(string) bool { return true })
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	bundle := buf.Bytes()
+
+	testcases := []struct {
+		name    string
+		bundle  []byte
+		maxSize int64
+		wantErr string
+	}{
+		{
+			name:   "Files missing from the manifest are skipped",
+			bundle: appendToBundle(t, bundle, "entries/extra.json", []byte("not an entry")),
+		},
+		{
+			name:    "Manifest must come first",
+			bundle:  appendToBundle(t, rewriteBundle(t, bundle, dropManifest), manifestName, manifestOf(t, bundle)),
+			wantErr: "must be the first file",
+		},
+		{
+			name:    "Bundle over the size limit",
+			bundle:  bundle,
+			maxSize: 16,
+			wantErr: "larger than",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.maxSize > 0 {
+				defer func(old int64) { maxBundleSize = old }(maxBundleSize)
+				maxBundleSize = tc.maxSize
+			}
+
+			read, _, err := ReadBundle(bytes.NewReader(tc.bundle))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil || len(read) != 1 {
+				t.Errorf("expected 1 record, got %d, %v", len(read), err)
+			}
+		})
+	}
+}
+
+func TestBundleUnreadableRecords(t *testing.T) {
+	header := []byte(`{"key":"laps","expires_at":"2999-01-01T00:00:00Z","encoding":"gzip","raw_size":8}`)
+	data := append(bytes.Clone(gzipMagic), binary.BigEndian.AppendUint32(nil, uint32(len(header)))...)
+	data = append(data, header...)
+	data = append(data, "not gzip"...)
+	records := []Record{
+		{Key: "weekend:Belgium:2023", Data: []byte(`{"expires_at":"2999-01-01T00:00:00Z","data":"spa"}`)},
+		{Key: "laps", Data: data},
+	}
+
+	// The header is intact, so the bundle can be written, but the data
+	// does not inflate.
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadBundle(bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ReadBundle to report ErrCorrupt, got %v", err)
+	}
+
+	dst := &FileCache{Dir: t.TempDir()}
+	if _, err := dst.Import(records); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected Import to report ErrCorrupt, got %v", err)
+	}
+	if entries, _, _ := dst.Info(); len(entries) != 0 {
+		t.Errorf("expected nothing imported, got %+v", entries)
+	}
+}
+
+// rewriteBundle copies a bundle, passing every file through fn.
+func rewriteBundle(t *testing.T, bundle []byte, fn func(name string, data []byte) []byte) []byte {
+	t.Helper()
+
+	zr, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(zw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		if data = fn(hdr.Name, data); data == nil {
+			continue
+		}
+		hdr.Size = int64(len(data))
+		_ = tw.WriteHeader(hdr)
+		_, _ = tw.Write(data)
+	}
+	tw.Close()
+	zw.Close()
+	return out.Bytes()
+}
+
+// dropManifest removes the manifest when passed to rewriteBundle.
+func dropManifest(name string, data []byte) []byte {
+	if name == manifestName {
+		return nil
+	}
+	return data
+}
+
+// manifestOf returns the manifest file of a bundle.
+func manifestOf(t *testing.T, bundle []byte) []byte {
+	t.Helper()
+
+	var manifest []byte
+	rewriteBundle(t, bundle, func(name string, data []byte) []byte {
+		if name == manifestName {
+			manifest = data
+		}
+		return data
+	})
+	return manifest
+}
+
+// appendToBundle copies a bundle with an extra file at the end.
+func appendToBundle(t *testing.T, bundle []byte, name string, data []byte) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(zw)
+	rewriteBundle(t, bundle, func(n string, d []byte) []byte {
+		if err := writeTarFile(tw, n, d, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	})
+	if err := writeTarFile(tw, name, data, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	zw.Close()
+	return out.Bytes()
+}
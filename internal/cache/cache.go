@@ -1,24 +1,52 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 type CacheEntry struct {
+	Key string `json:"key,omitempty"`
+	// Type and Version identify what Data holds; see Codec.
+	Type      string      `json:"type,omitempty"`
+	Version   int         `json:"version,omitempty"`
 	CreatedAt time.Time   `json:"created_at"`
 	ExpiresAt time.Time   `json:"expires_at"`
-	Data      interface{} `json:"data"`
+	Data      interface{} `json:"data,omitempty"`
+	// Large entries are stored compressed after a header holding the rest
+	// of the entry: Encoding names the compression and RawSize is the size
+	// of Data before compression.
+	Encoding string `json:"encoding,omitempty"`
+	RawSize  int64  `json:"raw_size,omitempty"`
 }
 
 type InfoEntry struct {
 	Key       string
+	File      string
 	CreatedAt time.Time
 	ExpiresAt time.Time
 	IsStale   bool
-	Size      int64
+	// AccessedAt is when the entry was last read or written.
+	AccessedAt time.Time
+	// Corrupt entries are no longer served. They have been moved to the
+	// quarantine directory unless the cache is read-only.
+	Corrupt bool
+	// Size is the size on disk; RawSize is the size of the data before
+	// compression.
+	Size    int64
+	RawSize int64
 }
 
 type Cache interface {
@@ -28,72 +56,247 @@ type Cache interface {
 	Info() ([]InfoEntry, string, error)
 }
 
+// Meta is the bookkeeping stored alongside an entry.
+type Meta struct {
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (m Meta) IsStale(now time.Time) bool {
+	return now.After(m.ExpiresAt)
+}
+
+// MetaGetter is implemented by caches that can return an entry's metadata
+// along with its value, e.g. to report how old cached data is.
+type MetaGetter interface {
+	GetWithMeta(key string, target interface{}) (Meta, bool, error)
+}
+
+// GetMeta reads key with its metadata when c is a MetaGetter, and falls back
+// to Get with empty metadata otherwise.
+func GetMeta(c Cache, key string, target interface{}) (Meta, bool, error) {
+	if mg, ok := c.(MetaGetter); ok {
+		return mg.GetWithMeta(key, target)
+	}
+	found, _, err := c.Get(key, target)
+	return Meta{}, found, err
+}
+
+// indexFile maps entry file names back to the keys they were stored under.
+const indexFile = "keys.index"
+
 type FileCache struct {
 	Dir string
+	// MaxBytes and MaxEntries limit the size of the cache. When a write
+	// takes the cache over either limit, the least recently used entries
+	// are evicted. Zero means no limit.
+	MaxBytes   int64
+	MaxEntries int
+	// CompressAbove is the encoded size in bytes above which entry data is
+	// gzipped. Zero uses DefaultCompressAbove; a negative value disables
+	// compression.
+	CompressAbove int
+
+	mu      sync.RWMutex
+	migrate sync.Once
+}
+
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// FileName returns the file a key is stored in: a readable prefix, which
+// cannot contain path separators or dots, followed by a hash of the full key
+// so distinct keys never collide. Compressed entries use the same name with
+// a .pwgz extension instead of .json.
+func FileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	prefix := strings.Trim(unsafeKeyChars.ReplaceAllString(key, "_"), "_")
+	if len(prefix) > 64 {
+		prefix = prefix[:64]
+	}
+
+	return prefix + "-" + hex.EncodeToString(sum[:8]) + ".json"
 }
 
 func (f *FileCache) Get(key string, target interface{}) (bool, bool, error) {
-	path := filepath.Join(f.Dir, key+".json")
-	data, err := os.ReadFile(path)
+	meta, found, err := f.GetWithMeta(key, target)
+	return found, found && meta.IsStale(time.Now()), err
+}
+
+func (f *FileCache) GetWithMeta(key string, target interface{}) (Meta, bool, error) {
+	f.ensureMigrated()
+
+	unlock, err := f.lock(false)
+	if err != nil {
+		return Meta{}, false, newError("get", key, f.Dir, err)
+	}
+	name, data, err := f.readEntryFile(key)
+	unlock()
+	path := filepath.Join(f.Dir, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return Meta{}, false, nil
+	}
+	if err != nil {
+		return Meta{}, false, newError("get", key, path, err)
+	}
+
+	entry, err := parseEntry(data)
 	if err != nil {
-		return false, false, nil
+		if qerr := f.quarantine(name); qerr != nil {
+			return Meta{}, false, &Error{Op: "get", Key: key, Path: path, Kind: ErrCorrupt, Err: fmt.Errorf("quarantine failed: %w", qerr)}
+		}
+		return Meta{}, false, &Error{Op: "get", Key: key, Path: path, Kind: ErrCorrupt, Err: err}
+	}
+
+	migrated, stale, err := decodeEntry(key, entry, target)
+	if err != nil {
+		return Meta{}, false, withPath(err, "get", key, path)
+	}
+	if stale {
+		entry = expire(entry)
 	}
 
-	var entry CacheEntry
-	entry.Data = target
-	if err := json.Unmarshal(data, &entry); err != nil {
-		return false, false, nil
+	if migrated {
+		f.rewrite(key, entry, target)
+	} else {
+		touch(path)
 	}
 
-	isStale := time.Now().After(entry.ExpiresAt)
-	return true, isStale, nil
+	return Meta{CreatedAt: entry.CreatedAt, ExpiresAt: entry.ExpiresAt}, true, nil
+}
+
+// rewrite stores a migrated entry in its current form, keeping its original
+// timestamps. Failures are ignored; the entry is migrated again next read.
+func (f *FileCache) rewrite(key string, old rawEntry, target interface{}) {
+	entry, err := newEntry(key, reflect.ValueOf(target).Elem().Interface(), 0)
+	if err != nil {
+		return
+	}
+	entry.CreatedAt = old.CreatedAt
+	entry.ExpiresAt = old.ExpiresAt
+
+	data, err := encodeEntry(entry, compressThreshold(f.CompressAbove))
+	if err != nil {
+		return
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	name, err := f.writeEntryFile(key, data)
+	if err != nil {
+		return
+	}
+	_ = f.updateIndex(func(index map[string]string) bool {
+		return indexEntry(index, key, name)
+	})
 }
 
 func (f *FileCache) Set(key string, value interface{}, ttl time.Duration) error {
-	_ = os.MkdirAll(f.Dir, 0755)
-	entry := CacheEntry{
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(ttl),
-		Data:      value,
+	f.ensureMigrated()
+
+	entry, err := newEntry(key, value, ttl)
+	if err != nil {
+		return err
+	}
+	data, err := encodeEntry(entry, compressThreshold(f.CompressAbove))
+	if err != nil {
+		return &Error{Op: "set", Key: key, Err: err}
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return newError("set", key, f.Dir, err)
+	}
+	defer unlock()
+
+	name, err := f.writeEntryFile(key, data)
+	if err != nil {
+		return newError("set", key, filepath.Join(f.Dir, name), err)
 	}
-	data, _ := json.Marshal(entry)
-	return os.WriteFile(filepath.Join(f.Dir, key+".json"), data, 0644)
+
+	err = f.updateIndex(func(index map[string]string) bool {
+		return indexEntry(index, key, name)
+	})
+	if err != nil {
+		return newError("set", key, filepath.Join(f.Dir, indexFile), err)
+	}
+
+	if err := f.evictLocked(key); err != nil {
+		return newError("set", key, f.Dir, err)
+	}
+	return nil
 }
 
 func (f *FileCache) Clear() (int, error) {
+	if _, err := os.Stat(f.Dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return 0, newError("clear", "", f.Dir, err)
+	}
+	defer unlock()
+
 	files, err := os.ReadDir(f.Dir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return 0, nil
-		}
-		return 0, err
+		return 0, newError("clear", "", f.Dir, err)
 	}
 
 	count := 0
 	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
-			err := os.Remove(filepath.Join(f.Dir, file.Name()))
+		name := file.Name()
+		if file.IsDir() {
+			continue
+		}
+		if isEntryFile(name) {
+			err := os.Remove(filepath.Join(f.Dir, name))
 			if err == nil {
 				count++
 			}
 		}
+		if strings.HasPrefix(name, tempPrefix) {
+			_ = os.Remove(filepath.Join(f.Dir, name))
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(f.Dir, quarantineDir)); err != nil {
+		return count, err
+	}
+	if err := os.Remove(filepath.Join(f.Dir, indexFile)); err != nil && !os.IsNotExist(err) {
+		return count, err
 	}
 	return count, nil
 }
 
 func (f *FileCache) Info() ([]InfoEntry, string, error) {
 	absPath, _ := filepath.Abs(f.Dir)
+	if _, err := os.Stat(f.Dir); os.IsNotExist(err) {
+		return nil, absPath, nil
+	}
+
+	f.ensureMigrated()
+
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, absPath, newError("info", "", absPath, err)
+	}
+
 	files, err := os.ReadDir(f.Dir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, absPath, nil
-		}
-		return nil, absPath, err
+		unlock()
+		return nil, absPath, newError("info", "", absPath, err)
 	}
 
+	index, _ := f.loadIndex()
+
 	var infos []InfoEntry
+	var corrupt []fs.DirEntry
 	for _, file := range files {
-		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+		if file.IsDir() || !isEntryFile(file.Name()) {
 			continue
 		}
 
@@ -103,20 +306,199 @@ func (f *FileCache) Info() ([]InfoEntry, string, error) {
 			continue
 		}
 
-		var entry CacheEntry
-		if err := json.Unmarshal(data, &entry); err != nil {
+		// Only the header is read, so a compressed entry whose data does
+		// not inflate is left for Get to find.
+		entry, err := parseHeader(data)
+		if err != nil {
+			corrupt = append(corrupt, file)
 			continue
 		}
 
+		key := index[file.Name()]
+		if key == "" {
+			key = entry.Key
+		}
+		if key == "" {
+			key = entryStem(file.Name())
+		}
+
+		fInfo, _ := file.Info()
+		infos = append(infos, InfoEntry{
+			Key:        key,
+			File:       file.Name(),
+			CreatedAt:  entry.CreatedAt,
+			ExpiresAt:  entry.ExpiresAt,
+			IsStale:    time.Now().After(entry.ExpiresAt),
+			AccessedAt: fInfo.ModTime(),
+			Size:       fInfo.Size(),
+			RawSize:    entry.rawSize(),
+		})
+	}
+
+	unlock()
+
+	// Corrupt entries are quarantined under the exclusive lock. If the
+	// cache is read-only they are reported where they are.
+	for _, file := range corrupt {
+		if err := f.quarantine(file.Name()); err != nil {
+			fInfo, _ := file.Info()
+			infos = append(infos, InfoEntry{
+				Key:     index[file.Name()],
+				File:    file.Name(),
+				Corrupt: true,
+				Size:    fInfo.Size(),
+			})
+		}
+	}
+
+	quarantined, _ := os.ReadDir(filepath.Join(f.Dir, quarantineDir))
+	index, _ = f.loadIndex()
+	for _, file := range quarantined {
+		name := quarantineDir + "/" + file.Name()
 		fInfo, _ := file.Info()
 		infos = append(infos, InfoEntry{
-			Key:       file.Name(),
-			CreatedAt: entry.CreatedAt,
-			ExpiresAt: entry.ExpiresAt,
-			IsStale:   time.Now().After(entry.ExpiresAt),
-			Size:      fInfo.Size(),
+			Key:     index[name],
+			File:    name,
+			Corrupt: true,
+			Size:    fInfo.Size(),
 		})
 	}
 
 	return infos, absPath, nil
 }
+
+// validEntry reports whether data is a complete cache entry; see
+// parseEntry.
+func validEntry(data []byte) bool {
+	_, err := parseEntry(data)
+	return err == nil
+}
+
+func (f *FileCache) quarantine(name string) error {
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another process may have rewritten the entry since it was read.
+	data, err := os.ReadFile(filepath.Join(f.Dir, name))
+	if err != nil || validEntry(data) {
+		return err
+	}
+	return f.quarantineLocked(name)
+}
+
+// quarantineLocked moves a corrupt entry aside so it is no longer served but
+// can still be inspected. The caller must hold the exclusive lock.
+func (f *FileCache) quarantineLocked(name string) error {
+	if err := os.MkdirAll(filepath.Join(f.Dir, quarantineDir), 0755); err != nil {
+		return err
+	}
+
+	moved := fmt.Sprintf("%s.%d", name, time.Now().UnixNano())
+	if err := os.Rename(filepath.Join(f.Dir, name), filepath.Join(f.Dir, quarantineDir, moved)); err != nil {
+		return err
+	}
+
+	return f.updateIndex(func(index map[string]string) bool {
+		if key, ok := index[name]; ok {
+			index[quarantineDir+"/"+moved] = key
+			delete(index, name)
+		}
+		return true
+	})
+}
+
+func (f *FileCache) loadIndex() (map[string]string, error) {
+	index := make(map[string]string)
+
+	data, err := os.ReadFile(filepath.Join(f.Dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return index, err
+	}
+
+	if err := json.Unmarshal(data, &index); err != nil {
+		return make(map[string]string), err
+	}
+	return index, nil
+}
+
+func (f *FileCache) saveIndex(index map[string]string) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(f.Dir, indexFile), data, 0644)
+}
+
+// updateIndex applies fn to the index and saves it if fn reports a change.
+// The caller must hold the exclusive lock.
+func (f *FileCache) updateIndex(fn func(index map[string]string) bool) error {
+	index, _ := f.loadIndex()
+	if !fn(index) {
+		return nil
+	}
+	return f.saveIndex(index)
+}
+
+func (f *FileCache) ensureMigrated() {
+	f.migrate.Do(func() {
+		_ = f.migrateLegacy()
+	})
+}
+
+// migrateLegacy renames entries written before keys were encoded, when the
+// raw key was the file name, and records every entry in the index.
+func (f *FileCache) migrateLegacy() error {
+	if _, err := os.Stat(f.Dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	files, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return err
+	}
+
+	return f.updateIndex(func(index map[string]string) bool {
+		changed := false
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || !isEntryFile(name) {
+				continue
+			}
+			if _, ok := index[name]; ok {
+				continue
+			}
+
+			var entry rawEntry
+			data, err := os.ReadFile(filepath.Join(f.Dir, name))
+			if err == nil {
+				entry, _ = parseHeader(data)
+			}
+			if entry.Key != "" && slices.Contains(entryFileNames(entry.Key), name) {
+				index[name] = entry.Key
+				changed = true
+				continue
+			}
+
+			key := entryStem(name)
+			encoded := entryFileName(key, data)
+			if err := os.Rename(filepath.Join(f.Dir, name), filepath.Join(f.Dir, encoded)); err != nil {
+				continue
+			}
+			index[encoded] = key
+			changed = true
+		}
+		return changed
+	})
+}
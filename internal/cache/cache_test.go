@@ -1,8 +1,11 @@
 package cache
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -57,6 +60,8 @@ func TestFileCache(t *testing.T) {
 	})
 
 	t.Run("Clear removes entries and leaves directory clean", func(t *testing.T) {
+		_, _ = fc.Clear()
+
 		_ = fc.Set("to_clear_1", "data", 1*time.Hour)
 		_ = fc.Set("to_clear_2", "data", 1*time.Hour)
 
@@ -103,8 +108,12 @@ func TestFileCache(t *testing.T) {
 			t.Fatalf("expected 1 info entry, got %d", len(infos))
 		}
 
-		if infos[0].Key != key+".json" {
-			t.Errorf("expected key %s.json, got %s", key, infos[0].Key)
+		if infos[0].Key != key {
+			t.Errorf("expected key %s, got %s", key, infos[0].Key)
+		}
+
+		if infos[0].File != FileName(key) {
+			t.Errorf("expected file %s, got %s", FileName(key), infos[0].File)
 		}
 
 		if infos[0].Size <= 0 {
@@ -112,3 +121,334 @@ func TestFileCache(t *testing.T) {
 		}
 	})
 }
+
+func TestFileCacheKeys(t *testing.T) {
+	t.Run("Unsafe keys stay inside the cache directory", func(t *testing.T) {
+		root := t.TempDir()
+		fc := &FileCache{Dir: filepath.Join(root, "cache")}
+
+		keys := []string{
+			"getsession:Belgium:2023:Race",
+			"weekend:../../escape:2023",
+			"weekend:a/b\\c:2023",
+		}
+		for _, key := range keys {
+			if err := fc.Set(key, key, time.Hour); err != nil {
+				t.Fatalf("set %q: %v", key, err)
+			}
+		}
+
+		outside, _ := os.ReadDir(root)
+		if len(outside) != 1 {
+			t.Errorf("expected only the cache directory in %s, found %d entries", root, len(outside))
+		}
+
+		for _, key := range keys {
+			var got string
+			found, _, _ := fc.Get(key, &got)
+			if !found || got != key {
+				t.Errorf("expected to read back %q, got %q (found %v)", key, got, found)
+			}
+		}
+
+		infos, _, _ := fc.Info()
+		if len(infos) != len(keys) {
+			t.Fatalf("expected %d entries, got %d", len(keys), len(infos))
+		}
+		for _, info := range infos {
+			if strings.ContainsAny(info.File, ":/\\") || strings.HasPrefix(info.File, ".") {
+				t.Errorf("unsafe file name %q for key %q", info.File, info.Key)
+			}
+		}
+	})
+
+	t.Run("Distinct keys do not collide", func(t *testing.T) {
+		if FileName("weekend:a/b:2023") == FileName("weekend:a:b:2023") {
+			t.Error("expected different file names for keys with the same readable prefix")
+		}
+	})
+
+	t.Run("Legacy entries are migrated", func(t *testing.T) {
+		dir := t.TempDir()
+		legacy := `{"created_at":"2024-01-01T00:00:00Z","expires_at":"2999-01-01T00:00:00Z","data":"old"}`
+		if err := os.WriteFile(filepath.Join(dir, "weekend:Belgium:2023.json"), []byte(legacy), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fc := &FileCache{Dir: dir}
+
+		var got string
+		found, _, _ := fc.Get("weekend:Belgium:2023", &got)
+		if !found || got != "old" {
+			t.Fatalf("expected migrated entry, got %q (found %v)", got, found)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "weekend:Belgium:2023.json")); !os.IsNotExist(err) {
+			t.Error("expected legacy file to be renamed")
+		}
+
+		infos, _, _ := fc.Info()
+		if len(infos) != 1 || infos[0].Key != "weekend:Belgium:2023" {
+			t.Errorf("expected original key in info, got %+v", infos)
+		}
+	})
+}
+
+func TestFileCacheConcurrency(t *testing.T) {
+	dir := t.TempDir()
+
+	// Separate instances share nothing but the directory, like separate
+	// processes do.
+	writer := &FileCache{Dir: dir}
+	reader := &FileCache{Dir: dir}
+
+	payload := make([]string, 2000)
+	for i := range payload {
+		payload[i] = "lap-data"
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := writer.Set("laps:9141", payload, time.Hour); err != nil {
+					t.Errorf("set: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		var got []string
+		found, _, _ := reader.Get("laps:9141", &got)
+		if found && len(got) != len(payload) {
+			t.Fatalf("read partial entry with %d items", len(got))
+		}
+	}
+	wg.Wait()
+
+	infos, _, _ := reader.Info()
+	for _, info := range infos {
+		if info.Corrupt {
+			t.Errorf("concurrent writes produced a corrupt entry: %+v", info)
+		}
+	}
+
+	files, _ := os.ReadDir(dir)
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), tempPrefix) {
+			t.Errorf("temporary file %s left behind", file.Name())
+		}
+	}
+}
+
+func TestFileCacheCorruption(t *testing.T) {
+	dir := t.TempDir()
+	fc := &FileCache{Dir: dir}
+
+	if err := fc.Set("weekend:Belgium:2023", "data", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.Set("weekend:Monaco:2023", "data", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a write interrupted part way through.
+	path := filepath.Join(dir, FileName("weekend:Belgium:2023"))
+	if err := os.WriteFile(path, []byte(`{"created_at":"2024-01-01T00:00:00Z","expi`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	found, _, err := fc.Get("weekend:Belgium:2023", &got)
+	if found {
+		t.Error("expected corrupt entry to be a miss")
+	}
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt, got %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected corrupt entry to be moved out of the cache")
+	}
+
+	infos, _, err := fc.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var corrupt []InfoEntry
+	for _, info := range infos {
+		if info.Corrupt {
+			corrupt = append(corrupt, info)
+		}
+	}
+	if len(corrupt) != 1 || corrupt[0].Key != "weekend:Belgium:2023" {
+		t.Fatalf("expected the quarantined entry in info, got %+v", infos)
+	}
+
+	found, _, _ = fc.Get("weekend:Monaco:2023", &got)
+	if !found {
+		t.Error("expected healthy entry to be unaffected")
+	}
+
+	if _, err := fc.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, quarantineDir)); !os.IsNotExist(err) {
+		t.Error("expected clear to remove quarantined entries")
+	}
+}
+
+func TestFileCacheErrors(t *testing.T) {
+	dir := t.TempDir()
+	fc := &FileCache{Dir: dir}
+
+	t.Run("Missing entry is not an error", func(t *testing.T) {
+		var got string
+		found, _, err := fc.Get("missing", &got)
+		if found || err != nil {
+			t.Errorf("expected a plain miss, got found=%v err=%v", found, err)
+		}
+	})
+
+	t.Run("Reading into the wrong type is a schema mismatch", func(t *testing.T) {
+		if err := fc.Set("weekend:Belgium:2023", map[string]string{"session": "Race"}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		found, _, err := fc.Get("weekend:Belgium:2023", &got)
+		if found {
+			t.Error("expected mismatched entry to be a miss")
+		}
+		if !errors.Is(err, ErrSchemaMismatch) {
+			t.Errorf("expected ErrSchemaMismatch, got %v", err)
+		}
+	})
+
+	t.Run("Unencodable values are reported", func(t *testing.T) {
+		if err := fc.Set("bad", func() {}, time.Hour); err == nil {
+			t.Error("expected an error for a value that cannot be encoded")
+		}
+	})
+
+	t.Run("Unwritable directory is reported", func(t *testing.T) {
+		file := filepath.Join(dir, "not-a-dir")
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		blocked := &FileCache{Dir: filepath.Join(file, "cache")}
+		if err := blocked.Set("key", "value", time.Hour); err == nil {
+			t.Error("expected an error when the cache directory cannot be created")
+		}
+	})
+}
+
+func TestFileCacheReadOnly(t *testing.T) {
+	t.Run("Reads create nothing", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "cache")
+		fc := &FileCache{Dir: dir}
+
+		var v string
+		if found, _, err := fc.Get("latest", &v); found || err != nil {
+			t.Errorf("expected a plain miss, got found=%v err=%v", found, err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected Get not to create the cache directory, got %v", err)
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		_, _, _ = fc.Get("latest", &v)
+		_, _, _ = fc.Info()
+		_, _ = fc.Verify()
+		_, _ = fc.Export(func(string) bool { return true })
+		if _, err := os.Stat(filepath.Join(dir, lockFile)); !os.IsNotExist(err) {
+			t.Errorf("expected reads not to create the lock file, got %v", err)
+		}
+	})
+
+	t.Run("Read-only directory", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("permissions are not enforced for root")
+		}
+
+		dir := t.TempDir()
+		fc := &FileCache{Dir: dir}
+		_ = fc.Set("weekend:Belgium:2023", "spa", time.Hour)
+		_ = os.WriteFile(filepath.Join(dir, FileName("weekend:Monaco:2023")), []byte("{"), 0644)
+		if err := os.Chmod(dir, 0555); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = os.Chmod(dir, 0755) })
+
+		reader := &FileCache{Dir: dir}
+		var v string
+		if found, _, err := reader.Get("weekend:Belgium:2023", &v); !found || err != nil || v != "spa" {
+			t.Errorf("expected to read the entry, got %q found=%v err=%v", v, found, err)
+		}
+
+		infos, _, err := reader.Info()
+		if err != nil || len(infos) != 2 {
+			t.Fatalf("expected 2 entries, got %+v, %v", infos, err)
+		}
+		if _, err := reader.Verify(); err != nil {
+			t.Errorf("expected verify to work, got %v", err)
+		}
+		if records, err := reader.Export(func(string) bool { return true }); err != nil || len(records) != 1 {
+			t.Errorf("expected 1 record exported, got %d, %v", len(records), err)
+		}
+	})
+}
+
+func TestFileCacheVerify(t *testing.T) {
+	dir := t.TempDir()
+	fc := &FileCache{Dir: dir}
+
+	_ = fc.Set("weekend:Belgium:2023", "data", time.Hour)
+	_ = fc.Set("weekend:Monaco:2023", "data", time.Hour)
+
+	problems, err := fc.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("expected a healthy cache, got %v", problems)
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, FileName("weekend:Belgium:2023")), []byte("{"), 0644)
+	_ = os.Remove(filepath.Join(dir, FileName("weekend:Monaco:2023")))
+	_ = os.WriteFile(filepath.Join(dir, "stray.json"), []byte(`{"expires_at":"2999-01-01T00:00:00Z","data":1}`), 0644)
+	before, _ := os.Stat(dir)
+
+	problems, err = fc.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.Stat(dir); !after.ModTime().Equal(before.ModTime()) {
+		t.Error("expected verify not to create or remove files")
+	}
+	if len(problems) != 3 {
+		t.Fatalf("expected 3 problems, got %d: %v", len(problems), problems)
+	}
+
+	corrupt := 0
+	for _, p := range problems {
+		if errors.Is(p, ErrCorrupt) {
+			corrupt++
+		}
+	}
+	if corrupt != 1 {
+		t.Errorf("expected 1 corrupt entry, got %d", corrupt)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, FileName("weekend:Belgium:2023"))); err != nil {
+		t.Error("expected verify to leave corrupt entries in place")
+	}
+}
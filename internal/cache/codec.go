@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Codec describes the type stored under a key prefix, so the type written
+// is always the type read back.
+type Codec struct {
+	// Type tags each entry with what it holds.
+	Type string
+	// Version must be bumped whenever the stored type changes shape.
+	Version int
+	// New returns a pointer to a zero value of the stored type.
+	New func() any
+	// Migrate converts an entry written with another type tag or version
+	// into the current type. It is optional; without it such entries are
+	// rejected as ErrSchemaMismatch. Wrap the result in MarkStale when the
+	// old entry lacks data the current type carries.
+	Migrate func(entryType string, version int, data json.RawMessage) (any, error)
+}
+
+// MarkStale wraps a value returned by Codec.Migrate that is missing data,
+// such as fields added since it was cached. The entry is written back as
+// expired, so it can still be served offline but is refetched when possible.
+func MarkStale(v any) any { return staleValue{v} }
+
+type staleValue struct{ v any }
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+// RegisterCodec sets the codec for keys starting with prefix. When prefixes
+// overlap the longest one wins. Registering a prefix twice panics.
+func RegisterCodec(prefix string, c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	if _, dup := codecs[prefix]; dup {
+		panic("cache: codec already registered for prefix " + prefix)
+	}
+	if c.New == nil {
+		panic("cache: codec for prefix " + prefix + " has no New func")
+	}
+	codecs[prefix] = c
+}
+
+func codecFor(key string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	prefixes := make([]string, 0, len(codecs))
+	for p := range codecs {
+		if strings.HasPrefix(key, p) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	if len(prefixes) == 0 {
+		return Codec{}, false
+	}
+
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	return codecs[prefixes[0]], true
+}
+
+// matchesCodec reports whether target is the type registered for key. Keys
+// without a codec accept any target.
+func matchesCodec(key string, target any) bool {
+	c, ok := codecFor(key)
+	return !ok || reflect.TypeOf(target) == reflect.TypeOf(c.New())
+}
+
+// rawEntry is CacheEntry with the payload left undecoded, so the header can
+// be checked before the data is trusted.
+type rawEntry struct {
+	Key       string          `json:"key,omitempty"`
+	Type      string          `json:"type,omitempty"`
+	Version   int             `json:"version,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Encoding  string          `json:"encoding,omitempty"`
+	RawSize   int64           `json:"raw_size,omitempty"`
+}
+
+// newEntry builds the entry stored for key, tagging it with its codec's
+// type and version. Values that do not match the registered type are
+// refused.
+func newEntry(key string, value any, ttl time.Duration) (CacheEntry, error) {
+	now := time.Now()
+	entry := CacheEntry{
+		Key:       key,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Data:      value,
+	}
+
+	c, ok := codecFor(key)
+	if !ok {
+		// A nil value has no type to tag it with, so it is stored untagged.
+		if value != nil {
+			entry.Type = reflect.TypeOf(value).String()
+		}
+		return entry, nil
+	}
+
+	want := reflect.TypeOf(c.New()).Elem()
+	if got := reflect.TypeOf(value); got != want {
+		return entry, &Error{Op: "set", Key: key, Kind: ErrSchemaMismatch, Err: fmt.Errorf("got %s, want %s", got, want)}
+	}
+
+	entry.Type = c.Type
+	entry.Version = c.Version
+	return entry, nil
+}
+
+// decodeEntry decodes a parsed entry into target. migrated reports that the
+// entry was converted by its codec and should be written back; stale that
+// the migration marked it for refetching.
+func decodeEntry(key string, entry rawEntry, target any) (migrated, stale bool, err error) {
+	c, ok := codecFor(key)
+	if !ok {
+		if err := json.Unmarshal(entry.Data, target); err != nil {
+			return false, false, &Error{Op: "get", Key: key, Kind: ErrSchemaMismatch, Err: err}
+		}
+		return false, false, nil
+	}
+
+	want := reflect.TypeOf(c.New())
+	if got := reflect.TypeOf(target); got != want {
+		return false, false, &Error{Op: "get", Key: key, Kind: ErrSchemaMismatch, Err: fmt.Errorf("read into %s, stored type is %s", got, want.Elem())}
+	}
+
+	if entry.Type == c.Type && entry.Version == c.Version {
+		if err := json.Unmarshal(entry.Data, target); err != nil {
+			return false, false, &Error{Op: "get", Key: key, Kind: ErrSchemaMismatch, Err: err}
+		}
+		return false, false, nil
+	}
+
+	v, stale, err := migrate(c, entry)
+	if err != nil {
+		return false, false, &Error{Op: "get", Key: key, Kind: ErrSchemaMismatch, Err: err}
+	}
+
+	reflect.ValueOf(target).Elem().Set(reflect.ValueOf(v))
+	return true, stale, nil
+}
+
+// migrate converts entry to c's current type.
+func migrate(c Codec, entry rawEntry) (v any, stale bool, err error) {
+	mismatch := fmt.Errorf("stored %s v%d, want %s v%d", describeType(entry.Type), entry.Version, c.Type, c.Version)
+	if c.Migrate == nil {
+		return nil, false, mismatch
+	}
+
+	v, err = c.Migrate(entry.Type, entry.Version, entry.Data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%v: %w", mismatch, err)
+	}
+	if s, ok := v.(staleValue); ok {
+		v, stale = s.v, true
+	}
+
+	want := reflect.TypeOf(c.New()).Elem()
+	if got := reflect.TypeOf(v); got != want {
+		return nil, false, fmt.Errorf("%v: migration returned %s", mismatch, got)
+	}
+	return v, stale, nil
+}
+
+// expire returns entry with its expiry moved into the past, unless it has
+// already expired.
+func expire(entry rawEntry) rawEntry {
+	if past := time.Now().Add(-time.Second); entry.ExpiresAt.After(past) {
+		entry.ExpiresAt = past
+	}
+	return entry
+}
+
+// checkEntry reports whether a parsed entry can be read under key without
+// decoding it into a caller's type.
+func checkEntry(op, key string, entry rawEntry) error {
+	c, ok := codecFor(key)
+	if !ok || (entry.Type == c.Type && entry.Version == c.Version) {
+		return nil
+	}
+	if _, _, err := migrate(c, entry); err != nil {
+		return &Error{Op: op, Key: key, Kind: ErrSchemaMismatch, Err: err}
+	}
+	return nil
+}
+
+func describeType(t string) string {
+	if t == "" {
+		return "untagged"
+	}
+	return t
+}
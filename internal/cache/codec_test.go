@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type lapV2 struct {
+	Number   int     `json:"number"`
+	Duration float64 `json:"duration"`
+}
+
+func init() {
+	RegisterCodec("codec-test:laps:", Codec{
+		Type:    "laps",
+		Version: 2,
+		New:     func() any { return new([]lapV2) },
+		Migrate: func(entryType string, version int, data json.RawMessage) (any, error) {
+			if entryType != "laps" || version != 1 {
+				return nil, fmt.Errorf("cannot migrate %s v%d", entryType, version)
+			}
+			var durations []float64
+			if err := json.Unmarshal(data, &durations); err != nil {
+				return nil, err
+			}
+			laps := make([]lapV2, len(durations))
+			for i, d := range durations {
+				laps[i] = lapV2{Number: i + 1, Duration: d}
+			}
+			return laps, nil
+		},
+	})
+	RegisterCodec("codec-test:strict:", Codec{
+		Type:    "strict",
+		Version: 1,
+		New:     func() any { return new(string) },
+	})
+	RegisterCodec("codec-test:partial:", Codec{
+		Type:    "partial",
+		Version: 2,
+		New:     func() any { return new(string) },
+		Migrate: func(entryType string, version int, data json.RawMessage) (any, error) {
+			var s string
+			if err := json.Unmarshal(data, &s); err != nil {
+				return nil, err
+			}
+			return MarkStale(s), nil
+		},
+	})
+}
+
+func TestCodecs(t *testing.T) {
+	dir := t.TempDir()
+	fc := &FileCache{Dir: dir}
+
+	writeRaw := func(t *testing.T, key, raw string) {
+		t.Helper()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, FileName(key)), []byte(raw), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Entries are tagged with type and version", func(t *testing.T) {
+		key := "codec-test:laps:9141"
+		if err := fc.Set(key, []lapV2{{Number: 1, Duration: 91.2}}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := os.ReadFile(filepath.Join(dir, FileName(key)))
+		var entry CacheEntry
+		_ = json.Unmarshal(data, &entry)
+		if entry.Type != "laps" || entry.Version != 2 {
+			t.Errorf("expected laps v2 header, got %s v%d", entry.Type, entry.Version)
+		}
+	})
+
+	t.Run("Set rejects the wrong type", func(t *testing.T) {
+		err := fc.Set("codec-test:laps:9142", []float64{91.2}, time.Hour)
+		if !errors.Is(err, ErrSchemaMismatch) {
+			t.Errorf("expected ErrSchemaMismatch, got %v", err)
+		}
+	})
+
+	t.Run("Get rejects the wrong target type", func(t *testing.T) {
+		var got []float64
+		found, _, err := fc.Get("codec-test:laps:9141", &got)
+		if found || !errors.Is(err, ErrSchemaMismatch) {
+			t.Errorf("expected ErrSchemaMismatch miss, got found=%v err=%v", found, err)
+		}
+	})
+
+	t.Run("Old versions are migrated and written back", func(t *testing.T) {
+		key := "codec-test:laps:9143"
+		writeRaw(t, key, `{"type":"laps","version":1,"created_at":"2024-01-01T00:00:00Z","expires_at":"2999-01-01T00:00:00Z","data":[91.2,90.8]}`)
+
+		var got []lapV2
+		found, _, err := fc.Get(key, &got)
+		if !found || err != nil {
+			t.Fatalf("expected migrated hit, got found=%v err=%v", found, err)
+		}
+		if len(got) != 2 || got[1] != (lapV2{Number: 2, Duration: 90.8}) {
+			t.Errorf("unexpected migrated value %+v", got)
+		}
+
+		data, _ := os.ReadFile(filepath.Join(dir, FileName(key)))
+		if !strings.Contains(string(data), `"version":2`) || !strings.Contains(string(data), `"expires_at":"2999-01-01T00:00:00Z"`) {
+			t.Errorf("expected entry to be rewritten as v2 with its expiry kept, got %s", data)
+		}
+	})
+
+	t.Run("Entries migrated as stale are rewritten expired", func(t *testing.T) {
+		key := "codec-test:partial:a"
+		writeRaw(t, key, `{"type":"partial","version":1,"created_at":"2024-01-01T00:00:00Z","expires_at":"2999-01-01T00:00:00Z","data":"old"}`)
+
+		for i := range 2 {
+			var got string
+			found, stale, err := fc.Get(key, &got)
+			if !found || !stale || err != nil || got != "old" {
+				t.Fatalf("read %d: expected stale hit, got found=%v stale=%v err=%v value=%q", i+1, found, stale, err, got)
+			}
+		}
+
+		data, _ := os.ReadFile(filepath.Join(dir, FileName(key)))
+		if !strings.Contains(string(data), `"version":2`) || strings.Contains(string(data), "2999") {
+			t.Errorf("expected entry to be rewritten as v2 and expired, got %s", data)
+		}
+	})
+
+	t.Run("Unmigratable entries are rejected", func(t *testing.T) {
+		key := "codec-test:strict:a"
+		writeRaw(t, key, `{"key":"codec-test:strict:a","created_at":"2024-01-01T00:00:00Z","expires_at":"2999-01-01T00:00:00Z","data":"legacy"}`)
+
+		var got string
+		found, _, err := fc.Get(key, &got)
+		if found || !errors.Is(err, ErrSchemaMismatch) {
+			t.Errorf("expected ErrSchemaMismatch miss, got found=%v err=%v", found, err)
+		}
+
+		problems, _ := fc.Verify()
+		mismatches := 0
+		for _, p := range problems {
+			if errors.Is(p, ErrSchemaMismatch) {
+				mismatches++
+			}
+		}
+		if mismatches != 1 {
+			t.Errorf("expected verify to report 1 schema mismatch, got %v", problems)
+		}
+	})
+
+	t.Run("Overlapping prefixes use the longest", func(t *testing.T) {
+		c, ok := codecFor("codec-test:laps:1")
+		if !ok || c.Type != "laps" {
+			t.Errorf("expected laps codec, got %+v", c)
+		}
+		if _, ok := codecFor("codec-test:other"); ok {
+			t.Error("expected no codec for an unregistered prefix")
+		}
+	})
+}
+
+func TestSetNil(t *testing.T) {
+	dir := t.TempDir()
+	bolt := NewBoltCache(filepath.Join(dir, BoltFile))
+
+	caches := []struct {
+		name  string
+		cache Cache
+	}{
+		{name: "File", cache: &FileCache{Dir: filepath.Join(dir, "file")}},
+		{name: "Layered", cache: NewLayered(&FileCache{Dir: filepath.Join(dir, "layered")}, 8)},
+		{name: "Bolt", cache: bolt},
+	}
+
+	for _, tc := range caches {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cache.Set("nil-value", nil, time.Hour); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got any
+			found, _, err := tc.cache.Get("nil-value", &got)
+			if err != nil || !found || got != nil {
+				t.Errorf("expected a cached nil, got %v %v %v", got, found, err)
+			}
+		})
+	}
+}
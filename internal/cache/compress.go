@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultCompressAbove is the encoded size above which FileCache compresses
+// an entry's data.
+const DefaultCompressAbove = 4 << 10
+
+const encodingGzip = "gzip"
+
+// compressThreshold resolves a CompressAbove setting to the threshold in
+// bytes, or -1 if compression is off.
+func compressThreshold(setting int) int {
+	switch {
+	case setting < 0:
+		return -1
+	case setting == 0:
+		return DefaultCompressAbove
+	default:
+		return setting
+	}
+}
+
+// Compressed entries are stored as gzipMagic, the entry's JSON header
+// without its data as a big-endian uint32 length and the bytes themselves,
+// then the gzip stream of the data. Plain entries are stored as JSON.
+var gzipMagic = []byte("PWGZ")
+
+func isCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic)
+}
+
+// maxHeaderSize bounds the JSON header of a compressed entry.
+const maxHeaderSize = 64 << 10
+
+// encodeEntry marshals entry, gzipping its data when the data is larger than
+// threshold. A negative threshold never compresses.
+func encodeEntry(entry CacheEntry, threshold int) ([]byte, error) {
+	if threshold < 0 {
+		return json.Marshal(entry)
+	}
+
+	data, err := json.Marshal(entry.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= threshold {
+		return json.Marshal(entry)
+	}
+
+	entry.Data = nil
+	entry.Encoding = encodingGzip
+	entry.RawSize = int64(len(data))
+	header, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(gzipMagic)
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(header))))
+	buf.Write(header)
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseHeader reads a stored entry's metadata without inflating compressed
+// data, which is left nil. It fails for anything that is not an entry.
+func parseHeader(data []byte) (rawEntry, error) {
+	entry, _, err := splitEntry(data)
+	return entry, err
+}
+
+// parseEntry reads a stored entry, inflating compressed data so Data always
+// holds the plain JSON value. A truncated write, data that does not
+// decompress or a file that is not an entry at all fails.
+func parseEntry(data []byte) (rawEntry, error) {
+	entry, body, err := splitEntry(data)
+	if err != nil || entry.Encoding == "" {
+		return entry, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return entry, err
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return entry, err
+	}
+	entry.Data = plain
+	return entry, nil
+}
+
+// splitEntry parses an entry's header and returns it with the compressed
+// body, if any.
+func splitEntry(data []byte) (rawEntry, []byte, error) {
+	var entry rawEntry
+
+	if !isCompressed(data) {
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return entry, nil, err
+		}
+		if entry.ExpiresAt.IsZero() {
+			return entry, nil, errors.New("missing expiry")
+		}
+		if entry.Encoding != "" {
+			return entry, nil, fmt.Errorf("unknown encoding %q", entry.Encoding)
+		}
+		// A nil value is stored with its data left out.
+		if entry.Data == nil {
+			entry.Data = json.RawMessage("null")
+		}
+		return entry, nil, nil
+	}
+
+	rest := data[len(gzipMagic):]
+	if len(rest) < 4 {
+		return entry, nil, errors.New("truncated header")
+	}
+	n := binary.BigEndian.Uint32(rest)
+	rest = rest[4:]
+	if n > maxHeaderSize || int(n) > len(rest) {
+		return entry, nil, errors.New("truncated header")
+	}
+
+	if err := json.Unmarshal(rest[:n], &entry); err != nil {
+		return entry, nil, err
+	}
+	if entry.ExpiresAt.IsZero() {
+		return entry, nil, errors.New("missing expiry")
+	}
+	if entry.Encoding != encodingGzip {
+		return entry, nil, fmt.Errorf("unknown encoding %q", entry.Encoding)
+	}
+	return entry, rest[n:], nil
+}
+
+// rawSize is the size of an entry's data before compression.
+func (e rawEntry) rawSize() int64 {
+	if e.Encoding != "" {
+		return e.RawSize
+	}
+	return int64(len(e.Data))
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileCacheCompression(t *testing.T) {
+	large := strings.Repeat("lap 1:45.123 ", 1000)
+
+	testcases := []struct {
+		name           string
+		compressAbove  int
+		value          string
+		wantCompressed bool
+	}{
+		{
+			name:           "Large entry is compressed",
+			value:          large,
+			wantCompressed: true,
+		},
+		{
+			name:  "Small entry is stored plain",
+			value: "small",
+		},
+		{
+			name:          "Compression disabled",
+			compressAbove: -1,
+			value:         large,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &FileCache{Dir: t.TempDir(), CompressAbove: tc.compressAbove}
+			if err := fc.Set("laps", tc.value, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			name := FileName("laps")
+			if tc.wantCompressed {
+				name = strings.TrimSuffix(name, ".json") + ".pwgz"
+			}
+			raw, err := os.ReadFile(filepath.Join(fc.Dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bytes.HasPrefix(raw, gzipMagic); got != tc.wantCompressed {
+				t.Errorf("expected compressed=%v, got %v", tc.wantCompressed, got)
+			}
+
+			var got string
+			found, _, err := fc.Get("laps", &got)
+			if err != nil || !found {
+				t.Fatalf("expected entry, got found=%v err=%v", found, err)
+			}
+			if got != tc.value {
+				t.Error("value did not round trip")
+			}
+
+			entries, _, _ := fc.Info()
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 entry, got %d", len(entries))
+			}
+			if tc.wantCompressed && entries[0].RawSize <= entries[0].Size {
+				t.Errorf("expected raw size %d to exceed size on disk %d", entries[0].RawSize, entries[0].Size)
+			}
+		})
+	}
+}
+
+func TestFileCacheCompressedFileNames(t *testing.T) {
+	large := strings.Repeat("lap 1:45.123 ", 1000)
+	plain := FileName("laps")
+	compressed := strings.TrimSuffix(plain, ".json") + ".pwgz"
+
+	exists := func(fc *FileCache, name string) bool {
+		_, err := os.Stat(filepath.Join(fc.Dir, name))
+		return err == nil
+	}
+
+	t.Run("Compressed entries stored as json are still read", func(t *testing.T) {
+		fc := &FileCache{Dir: t.TempDir()}
+		if err := fc.Set("laps", large, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		// Older versions stored compressed entries under the plain name.
+		if err := os.Rename(filepath.Join(fc.Dir, compressed), filepath.Join(fc.Dir, plain)); err != nil {
+			t.Fatal(err)
+		}
+		_ = fc.saveIndex(map[string]string{plain: "laps"})
+
+		var got string
+		if found, _, err := fc.Get("laps", &got); !found || err != nil || got != large {
+			t.Fatalf("expected entry, got found=%v err=%v", found, err)
+		}
+		if problems, _ := fc.Verify(); len(problems) != 0 {
+			t.Errorf("expected no problems, got %v", problems)
+		}
+
+		if err := fc.Set("laps", large, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if exists(fc, plain) || !exists(fc, compressed) {
+			t.Error("expected the rewrite to move the entry to its compressed name")
+		}
+		if problems, _ := fc.Verify(); len(problems) != 0 {
+			t.Errorf("expected no problems, got %v", problems)
+		}
+	})
+
+	t.Run("Switching format replaces the other file", func(t *testing.T) {
+		fc := &FileCache{Dir: t.TempDir()}
+		if err := fc.Set("laps", large, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := fc.Set("laps", "small", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if !exists(fc, plain) || exists(fc, compressed) {
+			t.Error("expected only the plain file")
+		}
+
+		if err := fc.Set("laps", large, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if exists(fc, plain) || !exists(fc, compressed) {
+			t.Error("expected only the compressed file")
+		}
+		if entries, _, _ := fc.Info(); len(entries) != 1 || entries[0].Key != "laps" {
+			t.Errorf("expected one laps entry, got %+v", entries)
+		}
+
+		if removed, err := fc.Remove("laps"); !removed || err != nil {
+			t.Fatalf("expected removal, got %v, %v", removed, err)
+		}
+		if exists(fc, compressed) {
+			t.Error("expected the compressed file to be removed")
+		}
+	})
+}
+
+func TestFileCacheCorruptPayload(t *testing.T) {
+	fc := &FileCache{Dir: t.TempDir()}
+
+	header := []byte(`{"key":"laps","expires_at":"2999-01-01T00:00:00Z","encoding":"gzip","raw_size":8}`)
+	data := append(bytes.Clone(gzipMagic), binary.BigEndian.AppendUint32(nil, uint32(len(header)))...)
+	data = append(data, header...)
+	data = append(data, "not gzip"...)
+
+	path := filepath.Join(fc.Dir, FileName("laps"))
+	_ = os.WriteFile(path, data, 0644)
+
+	// Info only reads the header, so the entry is still listed.
+	if entries, _, _ := fc.Info(); len(entries) != 1 || entries[0].Corrupt {
+		t.Fatalf("expected the entry to be listed, got %+v", entries)
+	}
+
+	var v string
+	_, _, err := fc.Get("laps", &v)
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected corrupt payload to be quarantined")
+	}
+}
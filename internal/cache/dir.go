@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DefaultDir returns the directory pitwall caches data in:
+// $XDG_CACHE_HOME/pitwall when XDG_CACHE_HOME is set, otherwise pitwall
+// under os.UserCacheDir, e.g. ~/.cache/pitwall or ~/Library/Caches/pitwall.
+func DefaultDir() (string, error) {
+	if xdg := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "pitwall"), nil
+	}
+
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "pitwall"), nil
+}
+
+// MoveDir moves the entries of the file cache in from into the one in to,
+// keeping any newer entry already in to. Only entries that were imported
+// are removed from from, along with its index and lock file once they are
+// no longer needed, and from itself only if that leaves it empty; anything
+// else in it is left alone. It returns the number of entries moved.
+func MoveDir(from, to string) (int, error) {
+	absFrom, err := filepath.Abs(from)
+	if err != nil {
+		return 0, err
+	}
+	absTo, err := filepath.Abs(to)
+	if err != nil {
+		return 0, err
+	}
+	if absFrom == absTo {
+		return 0, nil
+	}
+
+	src := &FileCache{Dir: from}
+	records, err := src.Export(func(string) bool { return true })
+	if err != nil {
+		return 0, err
+	}
+
+	// Records are imported one at a time so only those actually written,
+	// not those kept back for a newer entry in to, are removed from from.
+	dst := &FileCache{Dir: to}
+	moved := 0
+	for _, r := range records {
+		n, err := dst.Import([]Record{r})
+		if err != nil {
+			return moved, err
+		}
+		if n == 0 {
+			continue
+		}
+		moved++
+		if _, err := src.Remove(r.Key); err != nil {
+			return moved, err
+		}
+	}
+
+	if moved > 0 {
+		if index, err := src.loadIndex(); err == nil && len(index) == 0 {
+			_ = os.Remove(filepath.Join(from, indexFile))
+		}
+		_ = os.Remove(filepath.Join(from, lockFile))
+		_ = os.Remove(from)
+	}
+	return moved, nil
+}
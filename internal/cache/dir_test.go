@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultDir(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", xdg)
+
+	dir, err := DefaultDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(xdg, "pitwall"); dir != want {
+		t.Errorf("expected %s, got %s", want, dir)
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	t.Run("Newer entries in the target are kept", func(t *testing.T) {
+		legacy := filepath.Join(t.TempDir(), ".pitwall_cache")
+		src := &FileCache{Dir: legacy}
+		_ = src.Set("weekend:Belgium:2023", "spa", time.Hour)
+		_ = src.Set("latest", "old", time.Hour)
+
+		dst := &FileCache{Dir: t.TempDir()}
+		_ = dst.Set("latest", "new", time.Hour)
+
+		n, err := MoveDir(legacy, dst.Dir)
+		if err != nil || n != 1 {
+			t.Fatalf("expected 1 entry moved, got %d, %v", n, err)
+		}
+
+		var v string
+		if found, _, _ := dst.Get("weekend:Belgium:2023", &v); !found || v != "spa" {
+			t.Errorf("expected moved entry, got %q", v)
+		}
+		if _, _, _ = dst.Get("latest", &v); v != "new" {
+			t.Errorf("expected newer entry to be kept, got %q", v)
+		}
+
+		// The entry that was not moved stays where it was.
+		if found, _, _ := src.Get("latest", &v); !found || v != "old" {
+			t.Errorf("expected the entry that was not moved to stay, got %q (found %v)", v, found)
+		}
+		if found, _, _ := src.Get("weekend:Belgium:2023", &v); found {
+			t.Error("expected the moved entry to be removed from the legacy cache")
+		}
+	})
+
+	t.Run("Empty legacy directory is removed", func(t *testing.T) {
+		legacy := filepath.Join(t.TempDir(), ".pitwall_cache")
+		_ = (&FileCache{Dir: legacy}).Set("weekend:Belgium:2023", "spa", time.Hour)
+
+		n, err := MoveDir(legacy, t.TempDir())
+		if err != nil || n != 1 {
+			t.Fatalf("expected 1 entry moved, got %d, %v", n, err)
+		}
+		if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+			t.Errorf("expected legacy directory to be removed, got %v", err)
+		}
+	})
+
+	t.Run("Other files are left alone", func(t *testing.T) {
+		legacy := filepath.Join(t.TempDir(), ".pitwall_cache")
+		_ = (&FileCache{Dir: legacy}).Set("weekend:Belgium:2023", "spa", time.Hour)
+		for _, name := range []string{".gitkeep", "my-notes.txt"} {
+			if err := os.WriteFile(filepath.Join(legacy, name), []byte("keep"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if n, err := MoveDir(legacy, t.TempDir()); err != nil || n != 1 {
+			t.Fatalf("expected 1 entry moved, got %d, %v", n, err)
+		}
+		for _, name := range []string{".gitkeep", "my-notes.txt"} {
+			if _, err := os.Stat(filepath.Join(legacy, name)); err != nil {
+				t.Errorf("expected %s to be left alone, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Moving a directory onto itself does nothing", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = (&FileCache{Dir: dir}).Set("latest", "new", time.Hour)
+
+		if n, err := MoveDir(dir, dir); err != nil || n != 0 {
+			t.Errorf("expected moving a directory onto itself to do nothing, got %d, %v", n, err)
+		}
+	})
+}
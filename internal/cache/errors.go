@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+var (
+	// ErrCorrupt means an entry could not be parsed at all, typically after
+	// an interrupted write. Corrupt entries are quarantined.
+	ErrCorrupt = errors.New("corrupt entry")
+	// ErrSchemaMismatch means an entry parsed but does not hold the type the
+	// caller asked for.
+	ErrSchemaMismatch = errors.New("schema mismatch")
+	// ErrPermission means the cache directory or an entry is not accessible.
+	ErrPermission = errors.New("permission denied")
+)
+
+// Error describes a failed cache operation. Kind is one of the sentinel
+// errors above when the failure has been classified, so callers can use
+// errors.Is(err, cache.ErrCorrupt).
+type Error struct {
+	Op   string
+	Key  string
+	Path string
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	msg := "cache " + e.Op
+	if e.Key != "" {
+		msg += " " + e.Key
+	}
+	if e.Kind != nil {
+		msg += ": " + e.Kind.Error()
+	}
+	if e.Path != "" {
+		msg += fmt.Sprintf(" (%s)", e.Path)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+func newError(op, key, path string, err error) *Error {
+	e := &Error{Op: op, Key: key, Path: path, Err: err}
+	if errors.Is(err, fs.ErrPermission) {
+		e.Kind = ErrPermission
+	}
+	return e
+}
+
+// withPath returns err as an *Error for the entry at path, wrapping it as
+// op if it is not one already.
+func withPath(err error, op, key, path string) *Error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return newError(op, key, path, err)
+	}
+	e.Path = path
+	return e
+}
+
+// Verifier is implemented by caches that can check their own storage and
+// report every problem found without modifying any entries.
+type Verifier interface {
+	Verify() ([]*Error, error)
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	lockFile      = ".lock"
+	tempPrefix    = ".tmp-"
+	quarantineDir = "quarantine"
+)
+
+// lock serialises access to the cache directory across goroutines and, via
+// an advisory lock on a lock file, across processes such as a cron job and
+// an interactive command. Only an exclusive lock creates the directory and
+// lock file. The returned func releases it.
+func (f *FileCache) lock(exclusive bool) (func(), error) {
+	if exclusive {
+		f.mu.Lock()
+	} else {
+		f.mu.RLock()
+	}
+	release := func() {
+		if exclusive {
+			f.mu.Unlock()
+		} else {
+			f.mu.RUnlock()
+		}
+	}
+
+	var lf *os.File
+	var err error
+	if exclusive {
+		if err := os.MkdirAll(f.Dir, 0755); err != nil {
+			release()
+			return nil, err
+		}
+		lf, err = os.OpenFile(filepath.Join(f.Dir, lockFile), os.O_RDWR|os.O_CREATE, 0644)
+	} else {
+		// Readers never create anything, so a cache that does not exist
+		// yet or a read-only one can still be read. Without a lock file
+		// there has been no writer to wait for, and writes are atomic
+		// renames anyway.
+		lf, err = os.Open(filepath.Join(f.Dir, lockFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			return release, nil
+		}
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	if err := flock(lf, exclusive); err != nil {
+		lf.Close()
+		release()
+		return nil, err
+	}
+
+	return func() {
+		_ = funlock(lf)
+		lf.Close()
+		release()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path,
+// so readers see either the old or the new contents, never a partial write.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Plain entries are stored under FileName, which ends in plainExt, and
+// compressed ones with compressedExt instead, as they are not JSON.
+const (
+	plainExt      = ".json"
+	compressedExt = ".pwgz"
+)
+
+// entryFileNames returns the files key may be stored in, plain first.
+func entryFileNames(key string) []string {
+	name := FileName(key)
+	return []string{name, strings.TrimSuffix(name, plainExt) + compressedExt}
+}
+
+// entryFileName returns the file an encoded entry for key is written to.
+func entryFileName(key string, data []byte) string {
+	names := entryFileNames(key)
+	if isCompressed(data) {
+		return names[1]
+	}
+	return names[0]
+}
+
+func isEntryFile(name string) bool {
+	ext := filepath.Ext(name)
+	return (ext == plainExt || ext == compressedExt) && !strings.HasPrefix(name, ".")
+}
+
+// entryStem returns an entry file's name without its extension.
+func entryStem(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// readEntryFile reads the file key is stored in, in either format, and
+// returns its name. The caller must hold a lock.
+func (f *FileCache) readEntryFile(key string) (string, []byte, error) {
+	names := entryFileNames(key)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(f.Dir, name))
+		if !errors.Is(err, fs.ErrNotExist) {
+			return name, data, err
+		}
+	}
+	return names[0], nil, fs.ErrNotExist
+}
+
+// writeEntryFile stores an encoded entry for key, removing any copy in the
+// other format, and returns the name written. The caller must hold the
+// exclusive lock and index the name; see indexEntry.
+func (f *FileCache) writeEntryFile(key string, data []byte) (string, error) {
+	name := entryFileName(key, data)
+	if err := writeFileAtomic(filepath.Join(f.Dir, name), data, 0644); err != nil {
+		return name, err
+	}
+	for _, other := range entryFileNames(key) {
+		if other != name {
+			_ = os.Remove(filepath.Join(f.Dir, other))
+		}
+	}
+	return name, nil
+}
+
+// indexEntry records name as the file key is stored in and reports whether
+// the index changed.
+func indexEntry(index map[string]string, key, name string) bool {
+	changed := false
+	for _, other := range entryFileNames(key) {
+		if _, ok := index[other]; ok && other != name {
+			delete(index, other)
+			changed = true
+		}
+	}
+	if index[name] != key {
+		index[name] = key
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Layered is a Cache that keeps the most recently used entries in memory in
+// front of another Cache, usually a FileCache. Writes go to both layers.
+// Values are held in memory in encoded form, so callers never share slices.
+type Layered struct {
+	next Cache
+	size int
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+type memEntry struct {
+	key  string
+	data []byte
+	meta Meta
+}
+
+// NewLayered puts an in-memory LRU holding up to size entries in front of
+// next.
+func NewLayered(next Cache, size int) *Layered {
+	return &Layered{
+		next:  next,
+		size:  size,
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (l *Layered) Get(key string, target interface{}) (bool, bool, error) {
+	meta, found, err := l.GetWithMeta(key, target)
+	return found, found && meta.IsStale(time.Now()), err
+}
+
+func (l *Layered) GetWithMeta(key string, target interface{}) (Meta, bool, error) {
+	// Reads into the wrong type skip memory so the next layer reports the
+	// mismatch.
+	if e, ok := l.memGet(key); ok && matchesCodec(key, target) {
+		if err := json.Unmarshal(e.data, target); err == nil {
+			return e.meta, true, nil
+		}
+		l.memDelete(key)
+	}
+
+	var meta Meta
+	var found bool
+	var err error
+	if mg, ok := l.next.(MetaGetter); ok {
+		meta, found, err = mg.GetWithMeta(key, target)
+	} else {
+		var stale bool
+		found, stale, err = l.next.Get(key, target)
+		// Without metadata the best we know is whether it is stale now.
+		meta = Meta{ExpiresAt: time.Now().Add(time.Minute)}
+		if stale {
+			meta.ExpiresAt = time.Time{}
+		}
+	}
+	if !found {
+		return meta, false, err
+	}
+
+	if data, mErr := json.Marshal(target); mErr == nil {
+		l.memPut(key, data, meta)
+	}
+	return meta, true, err
+}
+
+func (l *Layered) Set(key string, value interface{}, ttl time.Duration) error {
+	entry, err := newEntry(key, value, ttl)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return &Error{Op: "set", Key: key, Err: err}
+	}
+
+	// The memory layer keeps serving the value even if the disk write
+	// fails, which matters most in long-running modes.
+	l.memPut(key, data, Meta{CreatedAt: entry.CreatedAt, ExpiresAt: entry.ExpiresAt})
+	return l.next.Set(key, value, ttl)
+}
+
+func (l *Layered) Clear() (int, error) {
+	l.mu.Lock()
+	l.lru.Init()
+	clear(l.items)
+	l.mu.Unlock()
+
+	return l.next.Clear()
+}
+
+func (l *Layered) Info() ([]InfoEntry, string, error) {
+	return l.next.Info()
+}
+
+func (l *Layered) Verify() ([]*Error, error) {
+	if v, ok := l.next.(Verifier); ok {
+		return v.Verify()
+	}
+	return nil, nil
+}
+
+func (l *Layered) Remove(key string) (bool, error) {
+	l.memDelete(key)
+	if p, ok := l.next.(Pruner); ok {
+		return p.Remove(key)
+	}
+	return false, nil
+}
+
+func (l *Layered) Prune(filter PruneFilter) ([]string, error) {
+	p, ok := l.next.(Pruner)
+	if !ok {
+		return nil, nil
+	}
+
+	removed, err := p.Prune(filter)
+	for _, key := range removed {
+		l.memDelete(key)
+	}
+	return removed, err
+}
+
+func (l *Layered) Export(match func(key string) bool) ([]Record, error) {
+	if e, ok := l.next.(Exporter); ok {
+		return e.Export(match)
+	}
+	return nil, nil
+}
+
+func (l *Layered) Import(records []Record) (int, error) {
+	e, ok := l.next.(Exporter)
+	if !ok {
+		return 0, nil
+	}
+	for _, r := range records {
+		l.memDelete(r.Key)
+	}
+	return e.Import(records)
+}
+
+func (l *Layered) memGet(key string) (*memEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.lru.MoveToFront(el)
+	return el.Value.(*memEntry), true
+}
+
+func (l *Layered) memPut(key string, data []byte, meta Meta) {
+	if l.size <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		el.Value = &memEntry{key: key, data: data, meta: meta}
+		l.lru.MoveToFront(el)
+		return
+	}
+
+	l.items[key] = l.lru.PushFront(&memEntry{key: key, data: data, meta: meta})
+	for l.lru.Len() > l.size {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.items, oldest.Value.(*memEntry).key)
+	}
+}
+
+func (l *Layered) memDelete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		l.lru.Remove(el)
+		delete(l.items, key)
+	}
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLayered(t *testing.T) {
+	t.Run("Serves from memory after the disk entry is gone", func(t *testing.T) {
+		dir := t.TempDir()
+		l := NewLayered(&FileCache{Dir: dir}, 2)
+
+		if err := l.Set("weekend:Belgium:2023", "spa", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		os.RemoveAll(dir)
+
+		var got string
+		found, isStale, err := l.Get("weekend:Belgium:2023", &got)
+		if !found || isStale || err != nil || got != "spa" {
+			t.Errorf("expected fresh memory hit, got found=%v stale=%v err=%v value=%q", found, isStale, err, got)
+		}
+	})
+
+	t.Run("Loads from the next layer and keeps expiry", func(t *testing.T) {
+		fc := &FileCache{Dir: t.TempDir()}
+		if err := fc.Set("stale", "old", -time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		l := NewLayered(fc, 2)
+
+		var got string
+		found, isStale, _ := l.Get("stale", &got)
+		if !found || !isStale {
+			t.Errorf("expected stale hit from disk, got found=%v stale=%v", found, isStale)
+		}
+
+		found, isStale, _ = l.Get("stale", &got)
+		if !found || !isStale {
+			t.Errorf("expected memory copy to still be stale, got found=%v stale=%v", found, isStale)
+		}
+	})
+
+	t.Run("Evicts least recently used entries", func(t *testing.T) {
+		dir := t.TempDir()
+		l := NewLayered(&FileCache{Dir: dir}, 2)
+
+		_ = l.Set("a", "1", time.Hour)
+		_ = l.Set("b", "2", time.Hour)
+
+		var got string
+		_, _, _ = l.Get("a", &got)
+		_ = l.Set("c", "3", time.Hour)
+
+		if _, ok := l.memGet("b"); ok {
+			t.Error("expected b to be evicted")
+		}
+		if _, ok := l.memGet("a"); !ok {
+			t.Error("expected recently used a to be kept")
+		}
+
+		found, _, _ := l.Get("b", &got)
+		if !found || got != "2" {
+			t.Errorf("expected evicted entry to be reloaded from disk, got %q", got)
+		}
+	})
+
+	t.Run("Memory copies are independent", func(t *testing.T) {
+		l := NewLayered(&FileCache{Dir: t.TempDir()}, 2)
+
+		value := []string{"Practice 1"}
+		_ = l.Set("k", value, time.Hour)
+		value[0] = "changed"
+
+		var got []string
+		_, _, _ = l.Get("k", &got)
+		if got[0] != "Practice 1" {
+			t.Errorf("expected stored copy to be unaffected, got %q", got[0])
+		}
+	})
+
+	t.Run("Codec checks still apply", func(t *testing.T) {
+		l := NewLayered(&FileCache{Dir: t.TempDir()}, 2)
+
+		if err := l.Set("codec-test:strict:x", 12, time.Hour); !errors.Is(err, ErrSchemaMismatch) {
+			t.Errorf("expected ErrSchemaMismatch on set, got %v", err)
+		}
+
+		_ = l.Set("codec-test:strict:y", "ok", time.Hour)
+		var wrong int
+		found, _, err := l.Get("codec-test:strict:y", &wrong)
+		if found || !errors.Is(err, ErrSchemaMismatch) {
+			t.Errorf("expected ErrSchemaMismatch on get, got found=%v err=%v", found, err)
+		}
+	})
+
+	t.Run("Clear empties both layers", func(t *testing.T) {
+		l := NewLayered(&FileCache{Dir: t.TempDir()}, 2)
+		_ = l.Set("k", "v", time.Hour)
+
+		if n, err := l.Clear(); err != nil || n != 1 {
+			t.Fatalf("expected 1 entry cleared, got %d (%v)", n, err)
+		}
+
+		var got string
+		if found, _, _ := l.Get("k", &got); found {
+			t.Error("expected entry to be gone")
+		}
+	})
+}
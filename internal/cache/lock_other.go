@@ -0,0 +1,15 @@
+//go:build !unix
+
+package cache
+
+import "os"
+
+// Advisory locks are not available here, so only the in-process lock held
+// by FileCache protects the cache directory.
+func flock(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func funlock(f *os.File) error {
+	return nil
+}
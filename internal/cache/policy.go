@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+)
+
+// TTLPolicy picks how long cached session data stays fresh from the state
+// of the sessions being cached.
+type TTLPolicy struct {
+	// Finished applies once every session has ended; their data no longer
+	// changes, so this is effectively permanent.
+	Finished time.Duration
+	// Live applies while any session is running.
+	Live time.Duration
+	// MaxFuture caps upcoming sessions, which otherwise expire at their
+	// start time, so schedule changes are still picked up.
+	MaxFuture time.Duration
+	// Latest caps entries that track whichever session is newest, because a
+	// new session can replace it at any time.
+	Latest time.Duration
+}
+
+func DefaultTTLPolicy() TTLPolicy {
+	return TTLPolicy{
+		Finished:  10 * 365 * 24 * time.Hour,
+		Live:      time.Minute,
+		MaxFuture: 24 * time.Hour,
+		Latest:    time.Hour,
+	}
+}
+
+// For returns the TTL for an entry holding sessions: the shortest TTL of any
+// session in it.
+func (p TTLPolicy) For(now time.Time, sessions ...domain.Session) time.Duration {
+	if len(sessions) == 0 {
+		return p.Live
+	}
+
+	ttl := p.Finished
+	for _, s := range sessions {
+		ttl = min(ttl, p.forSession(now, s))
+	}
+	return ttl
+}
+
+// ForLatest is For, capped for entries that follow the newest session.
+func (p TTLPolicy) ForLatest(now time.Time, sessions ...domain.Session) time.Duration {
+	return min(p.For(now, sessions...), p.Latest)
+}
+
+func (p TTLPolicy) forSession(now time.Time, s domain.Session) time.Duration {
+	switch s.State(now) {
+	case domain.StateFuture:
+		return min(s.DateStart.Sub(now), p.MaxFuture)
+	case domain.StateLive:
+		return p.Live
+	default:
+		return p.Finished
+	}
+}
+
+// ParseTTLPolicy overrides fields of base from a comma separated list such
+// as "live=30s,future=12h". Keys are finished, live, future and latest.
+func ParseTTLPolicy(s string, base TTLPolicy) (TTLPolicy, error) {
+	p := base
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return base, fmt.Errorf("cache ttl: expected name=duration, got %q", part)
+		}
+
+		d, err := ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return base, fmt.Errorf("cache ttl: %s: %w", name, err)
+		}
+
+		switch strings.TrimSpace(name) {
+		case "finished":
+			p.Finished = d
+		case "live":
+			p.Live = d
+		case "future":
+			p.MaxFuture = d
+		case "latest":
+			p.Latest = d
+		default:
+			return base, fmt.Errorf("cache ttl: unknown setting %q", name)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+)
+
+func TestTTLPolicy(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	p := DefaultTTLPolicy()
+
+	finished := domain.Session{DateStart: now.Add(-3 * time.Hour), DateEnd: now.Add(-time.Hour)}
+	live := domain.Session{DateStart: now.Add(-time.Hour), DateEnd: now.Add(time.Hour)}
+	soon := domain.Session{DateStart: now.Add(2 * time.Hour), DateEnd: now.Add(3 * time.Hour)}
+	later := domain.Session{DateStart: now.Add(72 * time.Hour), DateEnd: now.Add(74 * time.Hour)}
+
+	testcases := []struct {
+		name     string
+		sessions []domain.Session
+		latest   bool
+		want     time.Duration
+	}{
+		{name: "Finished session is effectively permanent", sessions: []domain.Session{finished}, want: p.Finished},
+		{name: "Live session is short", sessions: []domain.Session{live}, want: p.Live},
+		{name: "Future session expires at its start", sessions: []domain.Session{soon}, want: 2 * time.Hour},
+		{name: "Distant session is capped", sessions: []domain.Session{later}, want: p.MaxFuture},
+		{name: "Weekend uses the shortest TTL", sessions: []domain.Session{finished, soon, later}, want: 2 * time.Hour},
+		{name: "Latest finished session is capped", sessions: []domain.Session{finished}, latest: true, want: p.Latest},
+		{name: "No sessions", want: p.Live},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := p.For(now, tc.sessions...)
+			if tc.latest {
+				got = p.ForLatest(now, tc.sessions...)
+			}
+			if got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTTLPolicy(t *testing.T) {
+	base := DefaultTTLPolicy()
+
+	got, err := ParseTTLPolicy("live=30s, future=12h", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Live != 30*time.Second || got.MaxFuture != 12*time.Hour || got.Finished != base.Finished {
+		t.Errorf("unexpected policy %+v", got)
+	}
+
+	for _, bad := range []string{"live", "live=soon", "forever=1h"} {
+		if _, err := ParseTTLPolicy(bad, base); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PruneFilter selects the entries Prune removes. An entry must match every
+// field that is set; an empty filter only enforces the size limits.
+type PruneFilter struct {
+	// Stale removes entries that have expired.
+	Stale bool
+	// OlderThan removes entries created more than this long ago.
+	OlderThan time.Duration
+	// Prefix limits pruning to keys starting with it.
+	Prefix string
+}
+
+func (p PruneFilter) empty() bool {
+	return !p.Stale && p.OlderThan == 0 && p.Prefix == ""
+}
+
+func (p PruneFilter) matches(e InfoEntry, now time.Time) bool {
+	if p.Prefix != "" && !strings.HasPrefix(e.Key, p.Prefix) {
+		return false
+	}
+	if p.Stale && !now.After(e.ExpiresAt) {
+		return false
+	}
+	if p.OlderThan > 0 && now.Sub(e.CreatedAt) < p.OlderThan {
+		return false
+	}
+	return true
+}
+
+// Pruner is implemented by caches that can remove individual entries.
+type Pruner interface {
+	// Remove deletes the entry stored under key and reports whether there
+	// was one.
+	Remove(key string) (bool, error)
+	// Prune deletes the entries matching filter, then evicts the least
+	// recently used entries until the cache is within its limits. It
+	// returns the removed keys.
+	Prune(filter PruneFilter) ([]string, error)
+}
+
+func (f *FileCache) Remove(key string) (bool, error) {
+	f.ensureMigrated()
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return false, newError("remove", key, f.Dir, err)
+	}
+	defer unlock()
+
+	names := entryFileNames(key)
+	removed := false
+	for _, name := range names {
+		path := filepath.Join(f.Dir, name)
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, newError("remove", key, path, err)
+		}
+		removed = true
+	}
+	if !removed {
+		return false, nil
+	}
+
+	if err := f.updateIndex(func(index map[string]string) bool {
+		for _, name := range names {
+			delete(index, name)
+		}
+		return true
+	}); err != nil {
+		return true, newError("remove", key, filepath.Join(f.Dir, indexFile), err)
+	}
+	return true, nil
+}
+
+func (f *FileCache) Prune(filter PruneFilter) ([]string, error) {
+	if _, err := os.Stat(f.Dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	f.ensureMigrated()
+
+	unlock, err := f.lock(true)
+	if err != nil {
+		return nil, newError("prune", "", f.Dir, err)
+	}
+	defer unlock()
+
+	entries, err := f.entriesLocked()
+	if err != nil {
+		return nil, newError("prune", "", f.Dir, err)
+	}
+
+	now := time.Now()
+	var remove, keep []InfoEntry
+	for _, e := range entries {
+		if !filter.empty() && filter.matches(e, now) {
+			remove = append(remove, e)
+		} else {
+			keep = append(keep, e)
+		}
+	}
+	remove = append(remove, overLimit(keep, f.MaxBytes, f.MaxEntries, "")...)
+
+	return f.removeLocked(remove)
+}
+
+// evictLocked removes the least recently used entries until the cache is
+// within MaxBytes and MaxEntries, never evicting the entry for keep. The
+// caller must hold the exclusive lock.
+func (f *FileCache) evictLocked(keep string) error {
+	if f.MaxBytes <= 0 && f.MaxEntries <= 0 {
+		return nil
+	}
+
+	entries, err := f.listLocked()
+	if err != nil {
+		return err
+	}
+	_, err = f.removeLocked(overLimit(entries, f.MaxBytes, f.MaxEntries, keep))
+	return err
+}
+
+// overLimit returns the entries to evict, least recently used first, so the
+// rest fit within maxBytes and maxEntries. The entry for key keep is never
+// evicted.
+func overLimit(entries []InfoEntry, maxBytes int64, maxEntries int, keep string) []InfoEntry {
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	count := len(entries)
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].AccessedAt.Before(entries[j].AccessedAt) })
+
+	var evict []InfoEntry
+	for _, e := range entries {
+		overBytes := maxBytes > 0 && total > maxBytes
+		overCount := maxEntries > 0 && count > maxEntries
+		if !overBytes && !overCount {
+			break
+		}
+		if keep != "" && e.Key == keep {
+			continue
+		}
+		evict = append(evict, e)
+		total -= e.Size
+		count--
+	}
+	return evict
+}
+
+// listLocked lists the entries in the cache directory with only what
+// eviction needs: the key from the index, the file size and the last access
+// time. Unlike entriesLocked it does not read the entries themselves. The
+// caller must hold a lock.
+func (f *FileCache) listLocked() ([]InfoEntry, error) {
+	files, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	index, _ := f.loadIndex()
+
+	var entries []InfoEntry
+	for _, file := range files {
+		if file.IsDir() || !isEntryFile(file.Name()) {
+			continue
+		}
+
+		fInfo, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, InfoEntry{
+			Key:        index[file.Name()],
+			File:       file.Name(),
+			AccessedAt: fInfo.ModTime(),
+			Size:       fInfo.Size(),
+		})
+	}
+	return entries, nil
+}
+
+// entriesLocked lists the entries in the cache directory, skipping corrupt
+// ones. The caller must hold a lock.
+func (f *FileCache) entriesLocked() ([]InfoEntry, error) {
+	files, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	index, _ := f.loadIndex()
+
+	var entries []InfoEntry
+	for _, file := range files {
+		if file.IsDir() || !isEntryFile(file.Name()) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(f.Dir, file.Name()))
+		if err != nil {
+			continue
+		}
+
+		entry, err := parseHeader(data)
+		if err != nil {
+			continue
+		}
+
+		fInfo, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		key := index[file.Name()]
+		if key == "" {
+			key = entry.Key
+		}
+
+		entries = append(entries, InfoEntry{
+			Key:        key,
+			File:       file.Name(),
+			CreatedAt:  entry.CreatedAt,
+			ExpiresAt:  entry.ExpiresAt,
+			AccessedAt: fInfo.ModTime(),
+			Size:       fInfo.Size(),
+			RawSize:    entry.rawSize(),
+		})
+	}
+	return entries, nil
+}
+
+// removeLocked deletes entries and drops them from the index. The caller
+// must hold the exclusive lock.
+func (f *FileCache) removeLocked(entries []InfoEntry) ([]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, e := range entries {
+		path := filepath.Join(f.Dir, e.File)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return removed, newError("prune", e.Key, path, err)
+		}
+		removed = append(removed, e.Key)
+	}
+
+	err := f.updateIndex(func(index map[string]string) bool {
+		for _, e := range entries {
+			delete(index, e.File)
+		}
+		return true
+	})
+	if err != nil {
+		return removed, newError("prune", "", filepath.Join(f.Dir, indexFile), err)
+	}
+	return removed, nil
+}
+
+// touch records that an entry was read by bumping its modification time,
+// which eviction treats as the last access. Failures are ignored.
+func touch(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
+
+// ParseDuration is time.ParseDuration with an extra "d" unit for days, e.g.
+// "30d" or "1d12h".
+func ParseDuration(s string) (time.Duration, error) {
+	days, rest, ok := strings.Cut(s, "d")
+	if !ok {
+		return time.ParseDuration(s)
+	}
+
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	d := time.Duration(n) * 24 * time.Hour
+	if rest == "" {
+		return d, nil
+	}
+
+	r, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d + r, nil
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a byte size such as "500MB", "2G" or "4096".
+func ParseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			unit = u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(n) || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	// Infinity and sizes past the int64 range do not fit in a byte count.
+	total := n * float64(unit)
+	if total >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", size)
+	}
+	return int64(total), nil
+}
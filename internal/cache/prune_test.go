@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFileCachePrune(t *testing.T) {
+	testcases := []struct {
+		name   string
+		filter PruneFilter
+		want   []string
+	}{
+		{
+			name:   "Stale entries",
+			filter: PruneFilter{Stale: true},
+			want:   []string{"getsession:Monaco:2023:Race", "weekend:Monaco:2023"},
+		},
+		{
+			name:   "Stale entries under a prefix",
+			filter: PruneFilter{Stale: true, Prefix: "weekend:"},
+			want:   []string{"weekend:Monaco:2023"},
+		},
+		{
+			name:   "Older than",
+			filter: PruneFilter{OlderThan: 30 * 24 * time.Hour},
+			want:   []string{"weekend:Belgium:2019"},
+		},
+		{
+			name:   "Empty filter without limits",
+			filter: PruneFilter{},
+			want:   nil,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &FileCache{Dir: t.TempDir()}
+			_ = fc.Set("weekend:Belgium:2023", "data", time.Hour)
+			_ = fc.Set("weekend:Monaco:2023", "data", -time.Minute)
+			_ = fc.Set("getsession:Monaco:2023:Race", "data", -time.Minute)
+			_ = fc.Set("weekend:Belgium:2019", "data", time.Hour)
+			backdate(t, fc, "weekend:Belgium:2019", 60*24*time.Hour)
+
+			removed, err := fc.Prune(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			slices.Sort(removed)
+			if !slices.Equal(removed, tc.want) {
+				t.Errorf("expected %v removed, got %v", tc.want, removed)
+			}
+
+			var v string
+			for _, key := range tc.want {
+				if found, _, _ := fc.Get(key, &v); found {
+					t.Errorf("expected %s to be pruned", key)
+				}
+			}
+		})
+	}
+}
+
+func TestFileCacheRemove(t *testing.T) {
+	fc := &FileCache{Dir: t.TempDir()}
+	_ = fc.Set("weekend:Belgium:2023", "data", time.Hour)
+
+	removed, err := fc.Remove("weekend:Belgium:2023")
+	if err != nil || !removed {
+		t.Fatalf("expected entry to be removed, got %v, %v", removed, err)
+	}
+
+	removed, err = fc.Remove("weekend:Belgium:2023")
+	if err != nil || removed {
+		t.Errorf("expected nothing to remove, got %v, %v", removed, err)
+	}
+
+	index, _ := fc.loadIndex()
+	if len(index) != 0 {
+		t.Errorf("expected empty index, got %v", index)
+	}
+}
+
+func TestFileCacheEviction(t *testing.T) {
+	t.Run("Max entries evicts least recently used", func(t *testing.T) {
+		fc := &FileCache{Dir: t.TempDir(), MaxEntries: 2}
+		_ = fc.Set("a", "data", time.Hour)
+		_ = fc.Set("b", "data", time.Hour)
+		backdate(t, fc, "a", time.Hour)
+		backdate(t, fc, "b", 2*time.Hour)
+
+		// Reading b makes a the least recently used entry.
+		var v string
+		_, _, _ = fc.Get("b", &v)
+		_ = fc.Set("c", "data", time.Hour)
+
+		if found, _, _ := fc.Get("a", &v); found {
+			t.Error("expected a to be evicted")
+		}
+		for _, key := range []string{"b", "c"} {
+			if found, _, _ := fc.Get(key, &v); !found {
+				t.Errorf("expected %s to be kept", key)
+			}
+		}
+	})
+
+	t.Run("Max bytes keeps the entry just written", func(t *testing.T) {
+		fc := &FileCache{Dir: t.TempDir(), MaxBytes: 1}
+		_ = fc.Set("a", "data", time.Hour)
+		_ = fc.Set("b", "data", time.Hour)
+
+		entries, _, _ := fc.Info()
+		if len(entries) != 1 || entries[0].Key != "b" {
+			t.Errorf("expected only b to remain, got %+v", entries)
+		}
+	})
+
+	t.Run("Eviction does not read entries", func(t *testing.T) {
+		fc := &FileCache{Dir: t.TempDir(), MaxEntries: 1}
+		_ = fc.Set("a", "data", time.Hour)
+
+		// Eviction only looks at file sizes and times, so an entry it cannot
+		// parse still counts and is evicted like any other.
+		path := filepath.Join(fc.Dir, FileName("a"))
+		if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-time.Hour)
+		_ = os.Chtimes(path, old, old)
+		_ = fc.Set("b", "data", time.Hour)
+
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("expected a to be evicted")
+		}
+		var v string
+		if found, _, _ := fc.Get("b", &v); !found {
+			t.Error("expected b to be kept")
+		}
+	})
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d":   30 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+		"90m":   90 * time.Minute,
+	}
+	for in, want := range tests {
+		got, err := ParseDuration(in)
+		if err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"d", "xd", "1dfoo", ""} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"4096":  4096,
+		"500MB": 500 << 20,
+		"1.5G":  3 << 29,
+		"64 kb": 64 << 10,
+		"100B":  100,
+	}
+	for in, want := range tests {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"lots", "NaN", "Inf", "+Inf GB", "-1", "-0.5MB", "1e30GB"} {
+		if got, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) = %v; expected error", in, got)
+		}
+	}
+}
+
+// backdate makes key look created and last used age ago.
+func backdate(t *testing.T, fc *FileCache, key string, age time.Duration) {
+	t.Helper()
+
+	path := filepath.Join(fc.Dir, FileName(key))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry rawEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.CreatedAt = entry.CreatedAt.Add(-age)
+	if data, err = json.Marshal(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	then := time.Now().Add(-age)
+	if err := os.Chtimes(path, then, then); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Verify scans the cache directory and reports unreadable, corrupt,
+// quarantined and unindexed entries. Unlike Get and Info it never moves or
+// rewrites entries, and it only takes the shared lock, so it works on a
+// read-only cache.
+func (f *FileCache) Verify() ([]*Error, error) {
+	absPath, _ := filepath.Abs(f.Dir)
+	if _, err := os.Stat(f.Dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	unlock, err := f.lock(false)
+	if err != nil {
+		return nil, newError("verify", "", absPath, err)
+	}
+	defer unlock()
+
+	var problems []*Error
+
+	index, err := f.loadIndex()
+	if err != nil {
+		p := newError("verify", "", filepath.Join(absPath, indexFile), err)
+		if p.Kind == nil {
+			p.Kind = ErrCorrupt
+		}
+		problems = append(problems, p)
+	}
+
+	files, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return problems, newError("verify", "", absPath, err)
+	}
+
+	present := make(map[string]bool)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !isEntryFile(name) {
+			continue
+		}
+		present[name] = true
+		path := filepath.Join(absPath, name)
+		key := index[name]
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			problems = append(problems, newError("verify", key, path, err))
+			continue
+		}
+		entry, err := parseEntry(data)
+		if err != nil {
+			problems = append(problems, &Error{Op: "verify", Key: key, Path: path, Kind: ErrCorrupt, Err: err})
+			continue
+		}
+
+		if k := cmp.Or(key, entry.Key); k != "" {
+			if err := checkEntry("verify", k, entry); err != nil {
+				problems = append(problems, withPath(err, "verify", k, path))
+				continue
+			}
+		}
+		switch {
+		case key == "":
+			problems = append(problems, &Error{Op: "verify", Key: entry.Key, Path: path, Err: errors.New("entry is missing from the key index")})
+		case !slices.Contains(entryFileNames(key), name):
+			problems = append(problems, &Error{Op: "verify", Key: key, Path: path, Err: errors.New("file name does not match its key")})
+		}
+	}
+
+	for name, key := range index {
+		if filepath.Dir(name) == quarantineDir || present[name] {
+			continue
+		}
+		problems = append(problems, &Error{Op: "verify", Key: key, Path: filepath.Join(absPath, name), Err: errors.New("indexed entry is missing")})
+	}
+
+	quarantined, _ := os.ReadDir(filepath.Join(f.Dir, quarantineDir))
+	for _, file := range quarantined {
+		name := quarantineDir + "/" + file.Name()
+		problems = append(problems, &Error{Op: "verify", Key: index[name], Path: filepath.Join(absPath, name), Kind: ErrCorrupt, Err: errors.New("quarantined")})
+	}
+
+	return problems, nil
+}
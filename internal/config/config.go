@@ -0,0 +1,164 @@
+// Package config reads and writes pitwall's YAML config file. Settings are
+// grouped into named profiles; values missing from a profile fall back to
+// the default profile.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is used when neither --profile nor the file picks one, and
+// supplies values the selected profile leaves unset.
+const DefaultProfile = "default"
+
+type Config struct {
+	// Profile is the profile used when --profile is not given.
+	Profile  string              `yaml:"profile,omitempty"`
+	Profiles map[string]*Profile `yaml:"profiles,omitempty"`
+}
+
+type Profile struct {
+	FavouriteDrivers []string `yaml:"favourite_drivers,omitempty"`
+	FavouriteTeams   []string `yaml:"favourite_teams,omitempty"`
+	// Country, Year and SessionType are the defaults for get_session and
+	// weekend.
+	Country       string `yaml:"country,omitempty"`
+	Year          string `yaml:"year,omitempty"`
+	SessionType   string `yaml:"session_type,omitempty"`
+	APIBaseURL    string `yaml:"api_base_url,omitempty"`
+	Output        string `yaml:"output,omitempty"`
+	Timezone      string `yaml:"timezone,omitempty"`
+	RemindMinutes int    `yaml:"remind_minutes,omitempty"`
+	Cache         Cache  `yaml:"cache,omitempty"`
+}
+
+// Cache holds the same settings as the --cache-* flags.
+type Cache struct {
+	Dir           string `yaml:"dir,omitempty"`
+	Backend       string `yaml:"backend,omitempty"`
+	TTL           string `yaml:"ttl,omitempty"`
+	MaxSize       string `yaml:"max_size,omitempty"`
+	MaxEntries    int    `yaml:"max_entries,omitempty"`
+	CompressAbove string `yaml:"compress_above,omitempty"`
+}
+
+// DefaultPath returns where the config file lives:
+// $XDG_CONFIG_HOME/pitwall/config.yaml when XDG_CONFIG_HOME is set,
+// otherwise pitwall/config.yaml under os.UserConfigDir.
+func DefaultPath() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "pitwall", "config.yaml"), nil
+	}
+
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "pitwall", "config.yaml"), nil
+}
+
+// Load reads the config file at path. A missing file is an empty config.
+func Load(path string) (*Config, error) {
+	c := &Config{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the config to path, creating its directory if needed.
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ProfileName returns the profile to use: name if given, otherwise the
+// file's profile, otherwise DefaultProfile.
+func (c *Config) ProfileName(name string) string {
+	switch {
+	case name != "":
+		return name
+	case c.Profile != "":
+		return c.Profile
+	default:
+		return DefaultProfile
+	}
+}
+
+// Names returns the profiles defined in the file, sorted.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Resolve returns the settings for the named profile, filled in from the
+// default profile. Asking for a profile the file does not define is an
+// error, except for DefaultProfile, which may be empty.
+func (c *Config) Resolve(name string) (Profile, error) {
+	name = c.ProfileName(name)
+
+	p, ok := c.Profiles[name]
+	if !ok && name != DefaultProfile {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+
+	resolved := Profile{}
+	if base, ok := c.Profiles[DefaultProfile]; ok {
+		resolved = *base
+	}
+	if p == nil || name == DefaultProfile {
+		return resolved, nil
+	}
+
+	for _, s := range Settings {
+		if v := s.get(p); v != "" {
+			_ = s.set(&resolved, v)
+		}
+	}
+	return resolved, nil
+}
+
+// Edit returns the named profile as stored in the file, creating it if
+// needed, so it can be changed and saved.
+func (c *Config) Edit(name string) *Profile {
+	name = c.ProfileName(name)
+
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	if c.Profiles[name] == nil {
+		c.Profiles[name] = &Profile{}
+	}
+	return c.Profiles[name]
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := c.Resolve(""); err != nil || p.Country != "" {
+		t.Errorf("expected an empty default profile, got %+v, %v", p, err)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pitwall", "config.yaml")
+
+	c := &Config{}
+	p := c.Edit("")
+	for key, value := range map[string]string{
+		"country":           "Belgium",
+		"year":              "2023",
+		"favourite_drivers": "Verstappen, 44",
+		"cache.backend":     "bolt",
+		"cache.max_entries": "500",
+	} {
+		if err := Set(p, key, value); err != nil {
+			t.Fatalf("set %s: %v", key, err)
+		}
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := loaded.Resolve("")
+	if got.Country != "Belgium" || got.Year != "2023" || got.Cache.Backend != "bolt" || got.Cache.MaxEntries != 500 {
+		t.Errorf("unexpected profile %+v", got)
+	}
+	if !slices.Equal(got.FavouriteDrivers, []string{"Verstappen", "44"}) {
+		t.Errorf("expected favourite drivers to be split, got %v", got.FavouriteDrivers)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c := &Config{
+		Profile: "work",
+		Profiles: map[string]*Profile{
+			DefaultProfile: {Country: "Belgium", Year: "2023", RemindMinutes: 15},
+			"work":         {Year: "2024", Cache: Cache{Dir: "/tmp/pitwall"}},
+		},
+	}
+
+	p, err := c.Resolve("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Country != "Belgium" || p.Year != "2024" || p.RemindMinutes != 15 || p.Cache.Dir != "/tmp/pitwall" {
+		t.Errorf("expected work profile over the default, got %+v", p)
+	}
+
+	if p, _ := c.Resolve(DefaultProfile); p.Year != "2023" {
+		t.Errorf("expected the default profile, got %+v", p)
+	}
+
+	if _, err := c.Resolve("home"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{key: "country", value: "Monaco"},
+		{key: "year", value: "twenty", wantErr: true},
+		{key: "remind_minutes", value: "-5", wantErr: true},
+		{key: "cache.backend", value: "sqlite", wantErr: true},
+		{key: "cache.max_size", value: "lots", wantErr: true},
+		{key: "cache.compress_above", value: "off"},
+		{key: "cache.ttl", value: "live=forever", wantErr: true},
+		{key: "colour", value: "red", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := Set(&Profile{}, tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
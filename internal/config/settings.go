@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhopalg/pitwall/internal/cache"
+	"github.com/bhopalg/pitwall/internal/output"
+)
+
+// Setting is a key that `pitwall config get/set` can read and write.
+// Values are strings on the command line; lists are comma separated.
+type Setting struct {
+	Key   string
+	Usage string
+	get   func(p *Profile) string
+	set   func(p *Profile, value string) error
+}
+
+var Settings = []Setting{
+	{
+		Key:   "country",
+		Usage: "default country for get_session and weekend",
+		get:   func(p *Profile) string { return p.Country },
+		set:   func(p *Profile, v string) error { p.Country = v; return nil },
+	},
+	{
+		Key:   "year",
+		Usage: "default season for get_session and weekend",
+		get:   func(p *Profile) string { return p.Year },
+		set: func(p *Profile, v string) error {
+			if v != "" {
+				if _, err := strconv.Atoi(v); err != nil {
+					return fmt.Errorf("invalid year %q", v)
+				}
+			}
+			p.Year = v
+			return nil
+		},
+	},
+	{
+		Key:   "session_type",
+		Usage: "default session for get_session, e.g. Race",
+		get:   func(p *Profile) string { return p.SessionType },
+		set:   func(p *Profile, v string) error { p.SessionType = v; return nil },
+	},
+	{
+		Key:   "favourite_drivers",
+		Usage: "comma separated driver names or numbers",
+		get:   func(p *Profile) string { return strings.Join(p.FavouriteDrivers, ",") },
+		set:   func(p *Profile, v string) error { p.FavouriteDrivers = splitList(v); return nil },
+	},
+	{
+		Key:   "favourite_teams",
+		Usage: "comma separated team names",
+		get:   func(p *Profile) string { return strings.Join(p.FavouriteTeams, ",") },
+		set:   func(p *Profile, v string) error { p.FavouriteTeams = splitList(v); return nil },
+	},
+	{
+		Key:   "api_base_url",
+		Usage: "OpenF1 API base URL",
+		get:   func(p *Profile) string { return p.APIBaseURL },
+		set:   func(p *Profile, v string) error { p.APIBaseURL = strings.TrimSuffix(v, "/"); return nil },
+	},
+	{
+		Key:   "output",
+		Usage: "output format: table, json, yaml, csv or ndjson",
+		get:   func(p *Profile) string { return p.Output },
+		set: func(p *Profile, v string) error {
+			if _, err := output.ParseFormat(v); err != nil {
+				return err
+			}
+			p.Output = v
+			return nil
+		},
+	},
+	{
+		Key:   "timezone",
+		Usage: "time zone to show times in, e.g. Europe/London",
+		get:   func(p *Profile) string { return p.Timezone },
+		set: func(p *Profile, v string) error {
+			if _, err := time.LoadLocation(v); err != nil {
+				return fmt.Errorf("unknown time zone %q", v)
+			}
+			p.Timezone = v
+			return nil
+		},
+	},
+	{
+		Key:   "remind_minutes",
+		Usage: "how many minutes before a session remind triggers",
+		get:   func(p *Profile) string { return itoa(p.RemindMinutes) },
+		set:   func(p *Profile, v string) error { return setInt(&p.RemindMinutes, v) },
+	},
+	{
+		Key:   "cache.dir",
+		Usage: "directory to keep the cache in",
+		get:   func(p *Profile) string { return p.Cache.Dir },
+		set:   func(p *Profile, v string) error { p.Cache.Dir = v; return nil },
+	},
+	{
+		Key:   "cache.backend",
+		Usage: "cache storage: file or bolt",
+		get:   func(p *Profile) string { return p.Cache.Backend },
+		set: func(p *Profile, v string) error {
+			if v != "" && v != "file" && v != "bolt" {
+				return fmt.Errorf("unknown cache backend %q (want file or bolt)", v)
+			}
+			p.Cache.Backend = v
+			return nil
+		},
+	},
+	{
+		Key:   "cache.ttl",
+		Usage: "cache TTL overrides, e.g. live=30s,future=12h",
+		get:   func(p *Profile) string { return p.Cache.TTL },
+		set: func(p *Profile, v string) error {
+			if _, err := cache.ParseTTLPolicy(v, cache.DefaultTTLPolicy()); err != nil {
+				return err
+			}
+			p.Cache.TTL = v
+			return nil
+		},
+	},
+	{
+		Key:   "cache.max_size",
+		Usage: "evict least recently used entries above this size, e.g. 500MB",
+		get:   func(p *Profile) string { return p.Cache.MaxSize },
+		set: func(p *Profile, v string) error {
+			if v != "" {
+				if _, err := cache.ParseSize(v); err != nil {
+					return err
+				}
+			}
+			p.Cache.MaxSize = v
+			return nil
+		},
+	},
+	{
+		Key:   "cache.max_entries",
+		Usage: "evict least recently used entries above this count",
+		get:   func(p *Profile) string { return itoa(p.Cache.MaxEntries) },
+		set:   func(p *Profile, v string) error { return setInt(&p.Cache.MaxEntries, v) },
+	},
+	{
+		Key:   "cache.compress_above",
+		Usage: "gzip entries larger than this, e.g. 4KB, or off",
+		get:   func(p *Profile) string { return p.Cache.CompressAbove },
+		set: func(p *Profile, v string) error {
+			if v != "" && v != "off" {
+				if _, err := cache.ParseSize(v); err != nil {
+					return err
+				}
+			}
+			p.Cache.CompressAbove = v
+			return nil
+		},
+	},
+}
+
+func lookup(key string) (Setting, error) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, nil
+		}
+	}
+	return Setting{}, fmt.Errorf("unknown setting %q", key)
+}
+
+// Get returns a profile's value for key, or "" if it is unset.
+func Get(p *Profile, key string) (string, error) {
+	s, err := lookup(key)
+	if err != nil {
+		return "", err
+	}
+	return s.get(p), nil
+}
+
+// Set validates value and stores it in the profile. An empty value unsets
+// the key.
+func Set(p *Profile, key, value string) error {
+	s, err := lookup(key)
+	if err != nil {
+		return err
+	}
+	return s.set(p, strings.TrimSpace(value))
+}
+
+func splitList(v string) []string {
+	var list []string
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func itoa(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func setInt(dst *int, v string) error {
+	if v == "" {
+		*dst = 0
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid number %q", v)
+	}
+	*dst = n
+	return nil
+}
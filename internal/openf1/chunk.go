@@ -0,0 +1,205 @@
+package openf1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Sample is a timestamped record that can be fetched in time windows. It must
+// be comparable so identical records at window boundaries can be dropped.
+type Sample interface {
+	comparable
+	Timestamp() string
+}
+
+func (c CarData) Timestamp() string  { return c.Date }
+func (l Location) Timestamp() string { return l.Date }
+
+type ChunkOptions struct {
+	// Window is the length of each time range requested. Defaults to 5 minutes.
+	Window time.Duration
+	// Workers bounds both concurrent requests and the number of fetched
+	// windows held in memory. Defaults to 4.
+	Workers int
+	Format  Format
+}
+
+func (o ChunkOptions) withDefaults() ChunkOptions {
+	if o.Window <= 0 {
+		o.Window = 5 * time.Minute
+	}
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	return o
+}
+
+type window struct {
+	start time.Time
+	end   time.Time
+}
+
+type windowResult[T Sample] struct {
+	records []T
+	err     error
+}
+
+// FetchChunked splits [start, end) into windows and fetches path for each of
+// them concurrently, filtering on the date field. Results are passed to emit
+// one window at a time, sorted and deduplicated in time order, so only
+// opts.Workers windows are ever held in memory.
+func FetchChunked[T Sample](ctx context.Context, c *Client, path string, q url.Values, start, end time.Time, opts ChunkOptions, emit func([]T) error) error {
+	opts = opts.withDefaults()
+	windows := splitWindows(start, end, opts.Window)
+	if len(windows) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if opts.Format == FormatCSV {
+		q = withCSV(q)
+	}
+	base := q.Encode()
+
+	results := make([]chan windowResult[T], len(windows))
+	for i := range results {
+		results[i] = make(chan windowResult[T], 1)
+	}
+
+	slots := make(chan struct{}, opts.Workers)
+	go func() {
+		for i, w := range windows {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func() {
+				var records []T
+				err := c.get(ctx, path, windowQuery(base, w), opts.Format, &records)
+				results[i] <- windowResult[T]{records: records, err: err}
+			}()
+		}
+	}()
+
+	var last time.Time
+	seen := make(map[T]struct{})
+
+	for i, w := range windows {
+		var res windowResult[T]
+		select {
+		case res = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-slots
+
+		if res.err != nil {
+			return fmt.Errorf("openf1: fetching %s window %s: %w", path, w.start.Format(time.RFC3339), res.err)
+		}
+
+		records, err := sortSamples(res.records)
+		if err != nil {
+			return err
+		}
+
+		out := records[:0]
+		for _, r := range records {
+			t, _ := parseTimestamp(r.Timestamp())
+			if t.Before(last) {
+				continue
+			}
+			if t.After(last) {
+				last = t
+				clear(seen)
+			}
+			if _, dup := seen[r]; dup {
+				continue
+			}
+			seen[r] = struct{}{}
+			out = append(out, r)
+		}
+
+		if len(out) == 0 {
+			continue
+		}
+		if err := emit(out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StreamCarData fetches /car_data for a session between start and end.
+func (c *Client) StreamCarData(ctx context.Context, sessionKey int, start, end time.Time, opts ChunkOptions, emit func([]CarData) error) error {
+	q := url.Values{}
+	q.Set("session_key", fmt.Sprint(sessionKey))
+	return FetchChunked(ctx, c, "/car_data", q, start, end, opts, emit)
+}
+
+// StreamLocation fetches /location for a session between start and end.
+func (c *Client) StreamLocation(ctx context.Context, sessionKey int, start, end time.Time, opts ChunkOptions, emit func([]Location) error) error {
+	q := url.Values{}
+	q.Set("session_key", fmt.Sprint(sessionKey))
+	return FetchChunked(ctx, c, "/location", q, start, end, opts, emit)
+}
+
+func splitWindows(start, end time.Time, size time.Duration) []window {
+	var windows []window
+	for s := start; s.Before(end); s = s.Add(size) {
+		e := s.Add(size)
+		if e.After(end) {
+			e = end
+		}
+		windows = append(windows, window{start: s, end: e})
+	}
+	return windows
+}
+
+// windowQuery appends OpenF1's comparison filters, which url.Values cannot
+// express, to an already encoded query.
+func windowQuery(base string, w window) string {
+	filter := "date>=" + url.QueryEscape(w.start.UTC().Format(time.RFC3339Nano)) +
+		"&date<" + url.QueryEscape(w.end.UTC().Format(time.RFC3339Nano))
+	if base == "" {
+		return filter
+	}
+	return base + "&" + filter
+}
+
+func sortSamples[T Sample](records []T) ([]T, error) {
+	times := make([]time.Time, len(records))
+	for i, r := range records {
+		t, err := parseTimestamp(r.Timestamp())
+		if err != nil {
+			return nil, fmt.Errorf("openf1: sample has invalid date %q: %w", r.Timestamp(), err)
+		}
+		times[i] = t
+	}
+
+	sort.Stable(byTime[T]{records: records, times: times})
+	return records, nil
+}
+
+type byTime[T Sample] struct {
+	records []T
+	times   []time.Time
+}
+
+func (b byTime[T]) Len() int           { return len(b.records) }
+func (b byTime[T]) Less(i, j int) bool { return b.times[i].Before(b.times[j]) }
+func (b byTime[T]) Swap(i, j int) {
+	b.records[i], b.records[j] = b.records[j], b.records[i]
+	b.times[i], b.times[j] = b.times[j], b.times[i]
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,123 @@
+package openf1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchChunked(t *testing.T) {
+	start := time.Date(2023, 7, 30, 13, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Minute)
+
+	var samples []CarData
+	for i := 0; i < 600; i++ {
+		ts := start.Add(time.Duration(i) * time.Second).Format(time.RFC3339Nano)
+		samples = append(samples, CarData{Date: ts, DriverNumber: 1, Speed: i})
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		var from, to time.Time
+		for _, part := range strings.Split(r.URL.RawQuery, "&") {
+			if v, ok := strings.CutPrefix(part, "date>="); ok {
+				v, _ = url.QueryUnescape(v)
+				from, _ = time.Parse(time.RFC3339Nano, v)
+			}
+			if v, ok := strings.CutPrefix(part, "date<"); ok {
+				v, _ = url.QueryUnescape(v)
+				to, _ = time.Parse(time.RFC3339Nano, v)
+			}
+		}
+
+		// Return the window in reverse order and repeat the first sample to
+		// check sorting and deduplication.
+		var out []CarData
+		for i := len(samples) - 1; i >= 0; i-- {
+			ts, _ := time.Parse(time.RFC3339Nano, samples[i].Date)
+			if !ts.Before(from) && ts.Before(to) {
+				out = append(out, samples[i])
+			}
+		}
+		if len(out) > 0 {
+			out = append(out, out[len(out)-1])
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+
+	c := &Client{baseURL: srv.URL, http: srv.Client()}
+	opts := ChunkOptions{Window: time.Minute, Workers: 3}
+
+	t.Run("Streams every sample once in time order", func(t *testing.T) {
+		var got []CarData
+		err := c.StreamCarData(context.Background(), 9141, start, end, opts, func(batch []CarData) error {
+			got = append(got, batch...)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != len(samples) {
+			t.Fatalf("expected %d samples, got %d", len(samples), len(got))
+		}
+		for i := range got {
+			if got[i] != samples[i] {
+				t.Fatalf("sample %d out of order: got %+v", i, got[i])
+			}
+		}
+
+		if maxInFlight.Load() > int32(opts.Workers) {
+			t.Errorf("expected at most %d concurrent requests, saw %d", opts.Workers, maxInFlight.Load())
+		}
+	})
+
+	t.Run("Emit error stops the fetch", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		err := c.StreamCarData(context.Background(), 9141, start, end, opts, func(batch []CarData) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Fatalf("expected emit error, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected emit to be called once, got %d", calls)
+		}
+	})
+}
+
+func TestSplitWindows(t *testing.T) {
+	start := time.Date(2023, 7, 30, 13, 0, 0, 0, time.UTC)
+
+	windows := splitWindows(start, start.Add(150*time.Second), time.Minute)
+	if len(windows) != 3 {
+		t.Fatalf("expected 3 windows, got %d", len(windows))
+	}
+	if !windows[2].end.Equal(start.Add(150 * time.Second)) {
+		t.Errorf("expected last window to be clamped to end, got %v", windows[2].end)
+	}
+
+	if got := splitWindows(start, start, time.Minute); len(got) != 0 {
+		t.Errorf("expected no windows for an empty range, got %d", len(got))
+	}
+}
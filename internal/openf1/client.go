@@ -3,9 +3,22 @@ package openf1
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
+)
+
+// Format selects the encoding OpenF1 uses for a response.
+type Format int
+
+const (
+	FormatJSON Format = iota
+	// FormatCSV asks OpenF1 for csv=true responses, which are much smaller
+	// for high-frequency endpoints like /car_data and /location.
+	FormatCSV
 )
 
 type Client struct {
@@ -13,17 +26,74 @@ type Client struct {
 	http    *http.Client
 }
 
-func New() *Client {
-	return &Client{
-		baseURL: "https://api.openf1.org/v1",
+const DefaultBaseURL = "https://api.openf1.org/v1"
+
+type Option func(*Client)
+
+// WithBaseURL points the client at a different OpenF1-compatible server.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithTransport sets the RoundTripper used for every request, e.g. to replay
+// recorded fixtures instead of going to the network.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.http.Transport = rt
+	}
+}
+
+// ErrOffline is returned for every request made by a client created with
+// WithOffline.
+var ErrOffline = errors.New("openf1: offline")
+
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, ErrOffline
+}
+
+// WithOffline makes every request fail with ErrOffline without touching the
+// network.
+func WithOffline() Option {
+	return WithTransport(offlineTransport{})
+}
+
+func New(opts ...Option) *Client {
+	c := &Client{
+		baseURL: DefaultBaseURL,
 		http:    &http.Client{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *Client) Get(ctx context.Context, path string, q url.Values, out any) error {
+	return c.GetFormat(ctx, path, q, FormatJSON, out)
+}
+
+// GetCSV is Get using the CSV response format. out must be a pointer to a
+// slice of structs; columns are matched to fields by their json tags.
+func (c *Client) GetCSV(ctx context.Context, path string, q url.Values, out any) error {
+	return c.GetFormat(ctx, path, q, FormatCSV, out)
+}
+
+func (c *Client) GetFormat(ctx context.Context, path string, q url.Values, format Format, out any) error {
+	if format == FormatCSV {
+		q = withCSV(q)
+	}
+
+	return c.get(ctx, path, q.Encode(), format, out)
+}
+
+func (c *Client) get(ctx context.Context, path, rawQuery string, format Format, out any) error {
 	u := c.baseURL + path
-	if len(q) > 0 {
-		u += "?" + q.Encode()
+	if rawQuery != "" {
+		u += "?" + rawQuery
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
@@ -39,8 +109,25 @@ func (c *Client) Get(ctx context.Context, path string, q url.Values, out any) er
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("opend1: %s returned %d", path, resp.StatusCode)
+		return fmt.Errorf("openf1: %s returned %d", path, resp.StatusCode)
+	}
+
+	return decode(resp.Body, format, out)
+}
+
+func decode(r io.Reader, format Format, out any) error {
+	if format == FormatCSV {
+		return DecodeCSV(r, out)
 	}
 
-	return json.NewDecoder(resp.Body).Decode(out)
+	return json.NewDecoder(r).Decode(out)
+}
+
+func withCSV(q url.Values) url.Values {
+	cq := url.Values{}
+	for k, v := range q {
+		cq[k] = v
+	}
+	cq.Set("csv", "true")
+	return cq
 }
@@ -0,0 +1,147 @@
+package openf1
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeCSV reads an OpenF1 CSV response into out, which must be a pointer to
+// a slice of structs (or struct pointers). The header row is matched against
+// the json tags of the struct fields, so the same types serve both formats.
+// Unknown columns are ignored and empty cells leave the field at its zero
+// value, mirroring a JSON null.
+func DecodeCSV(r io.Reader, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("openf1: csv target must be a pointer to a slice, got %T", out)
+	}
+
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("openf1: csv target element must be a struct, got %s", elemType)
+	}
+
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("openf1: reading csv header: %w", err)
+	}
+
+	fields := csvFieldIndex(structType)
+	columns := make([][]int, len(header))
+	for i, name := range header {
+		columns[i] = fields[strings.TrimSpace(name)]
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, 0)
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("openf1: reading csv line %d: %w", line, err)
+		}
+
+		elem := reflect.New(structType).Elem()
+		for i, cell := range record {
+			if i >= len(columns) || columns[i] == nil || cell == "" {
+				continue
+			}
+			if err := setCSVField(elem.FieldByIndex(columns[i]), cell); err != nil {
+				return fmt.Errorf("openf1: csv line %d column %q: %w", line, header[i], err)
+			}
+		}
+
+		if elemType.Kind() == reflect.Pointer {
+			result = reflect.Append(result, elem.Addr())
+		} else {
+			result = reflect.Append(result, elem)
+		}
+	}
+
+	slice.Set(result)
+	return nil
+}
+
+func csvFieldIndex(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Index
+	}
+	return fields
+}
+
+func setCSVField(v reflect.Value, cell string) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setCSVField(p.Elem(), cell); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(cell)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// Integer columns occasionally arrive as "12.0" in CSV output.
+		n, err := strconv.ParseInt(cell, 10, v.Type().Bits())
+		if err != nil {
+			f, ferr := strconv.ParseFloat(cell, 64)
+			if ferr != nil || f != float64(int64(f)) {
+				return err
+			}
+			n = int64(f)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package openf1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	testcases := []struct {
+		name          string
+		input         string
+		expectedError bool
+		expectedLen   int
+	}{
+		{
+			name: "Maps columns by json tag in any order",
+			input: "session_key,date,driver_number,speed,unknown_column\n" +
+				"9159,2023-09-16T13:03:35.292000+00:00,55,315,x\n" +
+				"9159,2023-09-16T13:03:35.572000+00:00,55,316,y\n",
+			expectedLen: 2,
+		},
+		{
+			name:        "Header only",
+			input:       "date,driver_number,speed\n",
+			expectedLen: 0,
+		},
+		{
+			name:        "Empty body",
+			input:       "",
+			expectedLen: 0,
+		},
+		{
+			name:        "Empty cell leaves zero value",
+			input:       "date,driver_number,speed\n2023-09-16T13:03:35Z,55,\n",
+			expectedLen: 1,
+		},
+		{
+			name:          "Invalid number",
+			input:         "date,driver_number,speed\n2023-09-16T13:03:35Z,55,fast\n",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []CarData
+			err := DecodeCSV(strings.NewReader(tc.input), &got)
+
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tc.expectedError, err)
+			}
+
+			if !tc.expectedError && len(got) != tc.expectedLen {
+				t.Errorf("expected %d rows, got %d", tc.expectedLen, len(got))
+			}
+		})
+	}
+
+	t.Run("Field values", func(t *testing.T) {
+		input := "session_key,session_name,date_start,date_end,year\n" +
+			"9140,Sprint,2023-07-29T15:05:00+00:00,,2023\n"
+
+		var got []*Session
+		if err := DecodeCSV(strings.NewReader(input), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		want := Session{SessionKey: 9140, SessionName: "Sprint", DateStart: "2023-07-29T15:05:00+00:00", Year: 2023}
+		if len(got) != 1 || *got[0] != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("Rejects non-slice target", func(t *testing.T) {
+		var s Session
+		if err := DecodeCSV(strings.NewReader("session_key\n1\n"), &s); err == nil {
+			t.Error("expected error for non-slice target")
+		}
+	})
+}
+
+func TestClientGetFormat(t *testing.T) {
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		if gotQuery.Get("csv") == "true" {
+			w.Write([]byte("session_key,session_name\n9140,Sprint\n"))
+			return
+		}
+		w.Write([]byte(`[{"session_key":9140,"session_name":"Sprint"}]`))
+	}))
+	defer srv.Close()
+
+	c := &Client{baseURL: srv.URL, http: srv.Client()}
+
+	for _, format := range []Format{FormatJSON, FormatCSV} {
+		q := url.Values{}
+		q.Set("session_key", "9140")
+
+		var sessions []Session
+		if err := c.GetFormat(context.Background(), "/sessions", q, format, &sessions); err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+
+		if len(sessions) != 1 || sessions[0].SessionName != "Sprint" {
+			t.Errorf("format %d: unexpected sessions %+v", format, sessions)
+		}
+
+		if q.Has("csv") {
+			t.Errorf("format %d: caller's query values were modified", format)
+		}
+
+		if (gotQuery.Get("csv") == "true") != (format == FormatCSV) {
+			t.Errorf("format %d: unexpected query %v", format, gotQuery)
+		}
+	}
+}
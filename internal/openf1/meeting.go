@@ -0,0 +1,78 @@
+package openf1
+
+import "encoding/json"
+
+type Meeting struct {
+	MeetingKey          int    `json:"meeting_key"`
+	MeetingName         string `json:"meeting_name"`
+	MeetingOfficialName string `json:"meeting_official_name"`
+	Location            string `json:"location"`
+	CountryName         string `json:"country_name"`
+	CountryCode         string `json:"country_code"`
+	CircuitKey          int    `json:"circuit_key"`
+	CircuitName         string `json:"circuit_short_name"`
+	DateStart           string `json:"date_start"`
+	GMTOffset           string `json:"gmt_offset"`
+	Year                int    `json:"year"`
+}
+
+type Driver struct {
+	DriverNumber  int    `json:"driver_number"`
+	BroadcastName string `json:"broadcast_name"`
+	FullName      string `json:"full_name"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	NameAcronym   string `json:"name_acronym"`
+	TeamName      string `json:"team_name"`
+	TeamColour    string `json:"team_colour"`
+	CountryCode   string `json:"country_code"`
+	HeadshotURL   string `json:"headshot_url"`
+	MeetingKey    int    `json:"meeting_key"`
+	SessionKey    int    `json:"session_key"`
+}
+
+// Lap timings are null for laps OpenF1 could not time, e.g. the first lap's
+// sector 1 or laps interrupted by a red flag.
+type Lap struct {
+	DriverNumber    int      `json:"driver_number"`
+	LapNumber       int      `json:"lap_number"`
+	DateStart       string   `json:"date_start"`
+	LapDuration     *float64 `json:"lap_duration"`
+	DurationSector1 *float64 `json:"duration_sector_1"`
+	DurationSector2 *float64 `json:"duration_sector_2"`
+	DurationSector3 *float64 `json:"duration_sector_3"`
+	I1Speed         *int     `json:"i1_speed"`
+	I2Speed         *int     `json:"i2_speed"`
+	STSpeed         *int     `json:"st_speed"`
+	IsPitOutLap     bool     `json:"is_pit_out_lap"`
+	MeetingKey      int      `json:"meeting_key"`
+	SessionKey      int      `json:"session_key"`
+}
+
+type Stint struct {
+	DriverNumber   int    `json:"driver_number"`
+	StintNumber    int    `json:"stint_number"`
+	Compound       string `json:"compound"`
+	LapStart       int    `json:"lap_start"`
+	LapEnd         int    `json:"lap_end"`
+	TyreAgeAtStart int    `json:"tyre_age_at_start"`
+	MeetingKey     int    `json:"meeting_key"`
+	SessionKey     int    `json:"session_key"`
+}
+
+// SessionResult is a driver's classification in a session. Duration and
+// GapToLeader are a single value for races but one per part for
+// qualifying, so they are left undecoded.
+type SessionResult struct {
+	Position     *int            `json:"position"`
+	DriverNumber int             `json:"driver_number"`
+	NumberOfLaps int             `json:"number_of_laps"`
+	Points       float64         `json:"points"`
+	DNF          bool            `json:"dnf"`
+	DNS          bool            `json:"dns"`
+	DSQ          bool            `json:"dsq"`
+	Duration     json.RawMessage `json:"duration"`
+	GapToLeader  json.RawMessage `json:"gap_to_leader"`
+	MeetingKey   int             `json:"meeting_key"`
+	SessionKey   int             `json:"session_key"`
+}
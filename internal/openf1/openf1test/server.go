@@ -0,0 +1,368 @@
+// Package openf1test provides an in-process fake OpenF1 server for tests.
+//
+// The server answers the same query language as the real API, including
+// session_key=latest and comparison filters such as date>=2023-07-30, over an
+// in-memory dataset, and can inject failures and latency per endpoint.
+package openf1test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bhopalg/pitwall/internal/openf1"
+)
+
+// Record is a single JSON object returned by an endpoint.
+type Record map[string]any
+
+// Dataset maps an endpoint path such as "/sessions" to its records.
+type Dataset map[string][]Record
+
+// Endpoints served by the fake. Requests to any other path return 404.
+var Endpoints = []string{
+	"/car_data", "/drivers", "/intervals", "/laps", "/location", "/meetings",
+	"/pit", "/position", "/race_control", "/session_result", "/sessions",
+	"/starting_grid", "/stints", "/team_radio", "/weather",
+}
+
+type failure struct {
+	status int
+	// remaining is the number of requests left to fail; -1 fails forever.
+	remaining int
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	data     Dataset
+	failures map[string]*failure
+	latency  map[string]time.Duration
+	requests map[string]int
+}
+
+// NewServer starts a fake server over data and closes it when the test ends.
+func NewServer(t testing.TB, data Dataset) *Server {
+	t.Helper()
+
+	s := &Server{
+		data:     Dataset{},
+		failures: make(map[string]*failure),
+		latency:  make(map[string]time.Duration),
+		requests: make(map[string]int),
+	}
+	for endpoint, records := range data {
+		for _, r := range records {
+			s.data[endpoint] = append(s.data[endpoint], toRecord(r))
+		}
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Client returns an OpenF1 client pointed at the fake server.
+func (s *Server) Client(opts ...openf1.Option) *openf1.Client {
+	return openf1.New(append([]openf1.Option{openf1.WithBaseURL(s.URL)}, opts...)...)
+}
+
+// Add appends records to an endpoint. Each value is converted through its
+// JSON encoding, so openf1 structs and Records can be mixed freely.
+func (s *Server) Add(endpoint string, values ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range values {
+		s.data[endpoint] = append(s.data[endpoint], toRecord(v))
+	}
+}
+
+// Fail makes every request to endpoint return status until Recover is called.
+func (s *Server) Fail(endpoint string, status int) {
+	s.FailNext(endpoint, status, -1)
+}
+
+// FailNext makes the next n requests to endpoint return status.
+func (s *Server) FailNext(endpoint string, status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[endpoint] = &failure{status: status, remaining: n}
+}
+
+// Recover clears any injected failure for endpoint.
+func (s *Server) Recover(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, endpoint)
+}
+
+// SetLatency delays every response from endpoint by d. An empty endpoint
+// applies the delay to all endpoints.
+func (s *Server) SetLatency(endpoint string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency[endpoint] = d
+}
+
+// Requests reports how many requests endpoint has received.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	endpoint := r.URL.Path
+
+	s.mu.Lock()
+	s.requests[endpoint]++
+	delay := s.latency[""] + s.latency[endpoint]
+	var status int
+	if f, ok := s.failures[endpoint]; ok && f.remaining != 0 {
+		status = f.status
+		if f.remaining > 0 {
+			f.remaining--
+		}
+	}
+	s.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if !knownEndpoint(endpoint) {
+		http.NotFound(w, r)
+		return
+	}
+
+	filters, asCSV, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	filters = s.resolveLatest(filters)
+	result := []Record{}
+	for _, rec := range s.data[endpoint] {
+		if matchAll(rec, filters) {
+			result = append(result, rec)
+		}
+	}
+	s.mu.Unlock()
+
+	if asCSV {
+		w.Header().Set("Content-Type", "text/csv")
+		writeCSV(w, result)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// resolveLatest swaps session_key=latest and meeting_key=latest for the
+// highest key known, as OpenF1 does. Callers must hold s.mu.
+func (s *Server) resolveLatest(filters []filter) []filter {
+	for i, f := range filters {
+		if f.op != "=" || f.value != "latest" {
+			continue
+		}
+
+		source := "/sessions"
+		if f.field == "meeting_key" {
+			source = "/meetings"
+		}
+		if len(s.data[source]) == 0 {
+			source = "/sessions"
+		}
+
+		latest := -1.0
+		for _, rec := range s.data[source] {
+			if v, ok := rec[f.field].(float64); ok && v > latest {
+				latest = v
+			}
+		}
+		filters[i].value = strconv.FormatFloat(latest, 'f', -1, 64)
+	}
+	return filters
+}
+
+func knownEndpoint(endpoint string) bool {
+	for _, e := range Endpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+type filter struct {
+	field string
+	op    string
+	value string
+}
+
+// parseQuery reads OpenF1 filters from a raw query. url.ParseQuery cannot be
+// used because date>=X and speed<300 split on the wrong characters.
+func parseQuery(raw string) ([]filter, bool, error) {
+	var filters []filter
+	asCSV := false
+
+	for _, part := range strings.Split(raw, "&") {
+		if part == "" {
+			continue
+		}
+		part, err := url.QueryUnescape(part)
+		if err != nil {
+			return nil, false, err
+		}
+
+		i := strings.IndexAny(part, "<>=")
+		if i <= 0 {
+			return nil, false, fmt.Errorf("invalid filter %q", part)
+		}
+
+		f := filter{field: part[:i], op: part[i : i+1], value: part[i+1:]}
+		if f.op != "=" && strings.HasPrefix(f.value, "=") {
+			f.op += "="
+			f.value = f.value[1:]
+		}
+
+		if f.field == "csv" {
+			asCSV = f.value == "true"
+			continue
+		}
+		filters = append(filters, f)
+	}
+
+	return filters, asCSV, nil
+}
+
+func matchAll(rec Record, filters []filter) bool {
+	for _, f := range filters {
+		v, ok := rec[f.field]
+		if !ok || !match(v, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func match(v any, f filter) bool {
+	var cmp int
+	switch v := v.(type) {
+	case float64:
+		want, err := strconv.ParseFloat(f.value, 64)
+		if err != nil {
+			return false
+		}
+		cmp = compare(v, want)
+	case bool:
+		return f.op == "=" && strconv.FormatBool(v) == f.value
+	case string:
+		got, gotErr := parseTime(v)
+		want, wantErr := parseTime(f.value)
+		if gotErr == nil && wantErr == nil {
+			cmp = got.Compare(want)
+		} else {
+			cmp = strings.Compare(v, f.value)
+		}
+	default:
+		return false
+	}
+
+	switch f.op {
+	case "=":
+		return cmp == 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func compare(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+func writeCSV(w http.ResponseWriter, records []Record) {
+	columns := map[string]struct{}{}
+	for _, rec := range records {
+		for k := range rec {
+			columns[k] = struct{}{}
+		}
+	}
+	header := make([]string, 0, len(columns))
+	for k := range columns {
+		header = append(header, k)
+	}
+	sort.Strings(header)
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, rec := range records {
+		row := make([]string, len(header))
+		for i, k := range header {
+			if v, ok := rec[k]; ok && v != nil {
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+}
+
+// toRecord converts v through its JSON encoding, so Records hold the same
+// types whether they were given as structs or literals, e.g. float64 rather
+// than int for numbers, which is what matching compares against.
+func toRecord(v any) Record {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("openf1test: encoding %T: %v", v, err))
+	}
+
+	var r Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		panic(fmt.Sprintf("openf1test: %T is not a JSON object", v))
+	}
+	return r
+}
@@ -0,0 +1,117 @@
+package openf1test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bhopalg/pitwall/internal/openf1"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer(t, Dataset{
+		"/sessions": {
+			{"session_key": 9140, "session_name": "Sprint", "country_name": "Belgium", "year": 2023, "date_start": "2023-07-29T15:05:00+00:00"},
+			{"session_key": 9141, "session_name": "Race", "country_name": "Belgium", "year": 2023, "date_start": "2023-07-30T13:00:00+00:00"},
+		},
+	})
+	srv.Add("/laps",
+		openf1.Lap{SessionKey: 9141, DriverNumber: 1, LapNumber: 1},
+		openf1.Lap{SessionKey: 9141, DriverNumber: 1, LapNumber: 2},
+		openf1.Lap{SessionKey: 9141, DriverNumber: 1, LapNumber: 3},
+	)
+	srv.Add("/meetings", Record{"meeting_key": 1216, "year": 2023})
+	client := srv.Client()
+	ctx := context.Background()
+
+	testcases := []struct {
+		name        string
+		path        string
+		raw         string
+		expectedLen int
+	}{
+		{name: "Equality filter", path: "/sessions", raw: "country_name=Belgium&session_name=Race", expectedLen: 1},
+		{name: "Numeric filter on record literals", path: "/sessions", raw: "session_key=9140", expectedLen: 1},
+		{name: "Numeric filter on added record", path: "/meetings", raw: "meeting_key=1216&year=2023", expectedLen: 1},
+		{name: "Latest session", path: "/sessions", raw: "session_key=latest", expectedLen: 1},
+		{name: "Date comparison", path: "/sessions", raw: "date_start%3E%3D2023-07-30", expectedLen: 1},
+		{name: "Strict numeric comparison", path: "/laps", raw: "lap_number<3&lap_number>1", expectedLen: 1},
+		{name: "Inclusive numeric comparison", path: "/laps", raw: "lap_number<=2", expectedLen: 2},
+		{name: "No match", path: "/sessions", raw: "year=2024", expectedLen: 0},
+		{name: "Empty endpoint", path: "/drivers", raw: "", expectedLen: 0},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tc.path + "?" + tc.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var records []Record
+			if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != tc.expectedLen {
+				t.Errorf("expected %d records, got %d: %v", tc.expectedLen, len(records), records)
+			}
+		})
+	}
+
+	t.Run("Latest resolves to highest session key", func(t *testing.T) {
+		s, err := client.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s == nil || s.SessionKey != 9141 {
+			t.Errorf("expected session 9141, got %+v", s)
+		}
+	})
+
+	t.Run("CSV responses", func(t *testing.T) {
+		q := url.Values{}
+		q.Set("session_key", "9141")
+
+		var laps []openf1.Lap
+		if err := client.GetCSV(ctx, "/laps", q, &laps); err != nil {
+			t.Fatal(err)
+		}
+		if len(laps) != 3 || laps[2].LapNumber != 3 {
+			t.Errorf("unexpected laps %+v", laps)
+		}
+	})
+
+	t.Run("Injected failures", func(t *testing.T) {
+		srv.FailNext("/sessions", http.StatusServiceUnavailable, 1)
+
+		if _, err := client.Next(ctx); err == nil {
+			t.Error("expected injected failure")
+		}
+		if _, err := client.Next(ctx); err != nil {
+			t.Errorf("expected failure to be used up, got %v", err)
+		}
+	})
+
+	t.Run("Injected latency", func(t *testing.T) {
+		srv.SetLatency("/sessions", 200*time.Millisecond)
+		defer srv.SetLatency("/sessions", 0)
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		if _, err := client.Next(ctx); err == nil {
+			t.Error("expected timeout")
+		}
+	})
+
+	t.Run("Unknown endpoint", func(t *testing.T) {
+		var out []Record
+		if err := client.Get(ctx, "/nope", nil, &out); err == nil {
+			t.Error("expected error for unknown endpoint")
+		}
+	})
+}
@@ -0,0 +1,182 @@
+// Package replay provides an http.RoundTripper that records OpenF1 responses
+// to fixture files and replays them, so the CLI and tests can run against
+// captured race weekends without network access.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Mode int
+
+const (
+	// Replay serves responses from fixture files and never touches the network.
+	Replay Mode = iota
+	// Record forwards requests to Next and writes each response to a fixture.
+	Record
+)
+
+var ErrNoFixture = errors.New("replay: no fixture recorded")
+
+// Fixture is the on-disk form of a recorded response.
+type Fixture struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Text   string          `json:"text,omitempty"`
+}
+
+type Transport struct {
+	Dir  string
+	Mode Mode
+	// Next performs real requests when recording. Defaults to
+	// http.DefaultTransport.
+	Next http.RoundTripper
+}
+
+func New(dir string, mode Mode) *Transport {
+	return &Transport{Dir: dir, Mode: mode}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := filepath.Join(t.Dir, FixtureName(req.Method, req.URL))
+
+	if t.Mode == Record {
+		return t.record(req, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s %s (%s)", ErrNoFixture, req.Method, requestTarget(req.URL), path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fx Fixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		return nil, fmt.Errorf("replay: reading %s: %w", path, err)
+	}
+
+	body := []byte(fx.Body)
+	if fx.Text != "" {
+		body = []byte(fx.Text)
+	}
+
+	header := fx.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fx.Status, http.StatusText(fx.Status)),
+		StatusCode:    fx.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *Transport) record(req *http.Request, path string) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	fx := Fixture{
+		Method: req.Method,
+		URL:    requestTarget(req.URL),
+		Status: resp.StatusCode,
+		Header: http.Header{},
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		fx.Header.Set("Content-Type", ct)
+	}
+	if json.Valid(body) {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, body, "", "  "); err != nil {
+			return nil, err
+		}
+		fx.Body = buf.Bytes()
+	} else {
+		fx.Text = string(body)
+	}
+
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(fx); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// FixtureName returns the file a request is stored under. The host is left
+// out so fixtures recorded against one base URL replay against another, and
+// the query is canonicalised so parameter order does not matter.
+func FixtureName(method string, u *url.URL) string {
+	target := requestTarget(u)
+	sum := sha256.Sum256([]byte(method + " " + target))
+
+	readable := strings.Trim(unsafeChars.ReplaceAllString(strings.TrimPrefix(u.Path, "/"), "_"), "_")
+	if len(readable) > 60 {
+		readable = readable[:60]
+	}
+
+	return fmt.Sprintf("%s_%s_%s.json", strings.ToLower(method), readable, hex.EncodeToString(sum[:])[:12])
+}
+
+func requestTarget(u *url.URL) string {
+	target := u.EscapedPath()
+	if u.RawQuery == "" {
+		return target
+	}
+
+	// url.Values would mangle OpenF1 comparison filters such as date>=,
+	// so sort the raw query parts instead of re-encoding them.
+	parts := strings.Split(u.RawQuery, "&")
+	sort.Strings(parts)
+	return target + "?" + strings.Join(parts, "&")
+}
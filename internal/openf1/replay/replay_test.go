@@ -0,0 +1,67 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bhopalg/pitwall/internal/openf1"
+)
+
+func TestTransport(t *testing.T) {
+	dir := t.TempDir()
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"session_key":9141,"session_name":"Race","date_start":"2023-07-30T13:00:00+00:00","date_end":"2023-07-30T15:00:00+00:00","country_name":"Belgium"}]`))
+	}))
+
+	recorder := openf1.New(openf1.WithBaseURL(srv.URL), openf1.WithTransport(New(dir, Record)))
+	recorded, err := recorder.GetSession(context.Background(), "Belgium", "Race", "2023")
+	if err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+	srv.Close()
+
+	if calls != 1 {
+		t.Fatalf("expected 1 upstream call while recording, got %d", calls)
+	}
+
+	player := openf1.New(openf1.WithBaseURL("http://replay.invalid"), openf1.WithTransport(New(dir, Replay)))
+
+	t.Run("Replays recorded response without network", func(t *testing.T) {
+		replayed, err := player.GetSession(context.Background(), "Belgium", "Race", "2023")
+		if err != nil {
+			t.Fatalf("replay failed: %v", err)
+		}
+		if *replayed != *recorded {
+			t.Errorf("expected %+v, got %+v", recorded, replayed)
+		}
+	})
+
+	t.Run("Missing fixture is an error", func(t *testing.T) {
+		_, err := player.GetSession(context.Background(), "Monaco", "Race", "2023")
+		if !errors.Is(err, ErrNoFixture) {
+			t.Errorf("expected ErrNoFixture, got %v", err)
+		}
+	})
+}
+
+func TestFixtureName(t *testing.T) {
+	a, _ := url.Parse("https://api.openf1.org/v1/sessions?year=2023&country_name=Belgium")
+	b, _ := url.Parse("http://127.0.0.1:8080/v1/sessions?country_name=Belgium&year=2023")
+	c, _ := url.Parse("https://api.openf1.org/v1/sessions?country_name=Belgium&year=2024")
+
+	if FixtureName("GET", a) != FixtureName("GET", b) {
+		t.Error("expected host and query order to be ignored")
+	}
+
+	if FixtureName("GET", a) == FixtureName("GET", c) {
+		t.Error("expected different queries to map to different fixtures")
+	}
+}
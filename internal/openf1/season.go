@@ -0,0 +1,62 @@
+package openf1
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+func (c *Client) GetMeetings(ctx context.Context, year string) ([]Meeting, error) {
+	q := url.Values{}
+	q.Set("year", year)
+
+	var meetings []Meeting
+	if err := c.Get(ctx, "/meetings", q, &meetings); err != nil {
+		return nil, err
+	}
+	return meetings, nil
+}
+
+// GetWeekendMeetings returns a country's meetings in a season: usually just
+// the grand prix, but pre-season testing can share the country.
+func (c *Client) GetWeekendMeetings(ctx context.Context, country_name, year string) ([]Meeting, error) {
+	q := url.Values{}
+	q.Set("country_name", country_name)
+	q.Set("year", year)
+
+	var meetings []Meeting
+	if err := c.Get(ctx, "/meetings", q, &meetings); err != nil {
+		return nil, err
+	}
+	return meetings, nil
+}
+
+func (c *Client) GetLaps(ctx context.Context, sessionKey int) ([]Lap, error) {
+	var laps []Lap
+	if err := c.Get(ctx, "/laps", sessionQuery(sessionKey), &laps); err != nil {
+		return nil, err
+	}
+	return laps, nil
+}
+
+func (c *Client) GetStints(ctx context.Context, sessionKey int) ([]Stint, error) {
+	var stints []Stint
+	if err := c.Get(ctx, "/stints", sessionQuery(sessionKey), &stints); err != nil {
+		return nil, err
+	}
+	return stints, nil
+}
+
+func (c *Client) GetSessionResults(ctx context.Context, sessionKey int) ([]SessionResult, error) {
+	var results []SessionResult
+	if err := c.Get(ctx, "/session_result", sessionQuery(sessionKey), &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func sessionQuery(sessionKey int) url.Values {
+	q := url.Values{}
+	q.Set("session_key", strconv.Itoa(sessionKey))
+	return q
+}
@@ -12,4 +12,5 @@ type Session struct {
 	CircuitName string `json:"circuit_short_name"`
 	MeetingKey  int    `json:"meeting_key"`
 	Year        int    `json:"year"`
+	GMTOffset   string `json:"gmt_offset"`
 }
@@ -0,0 +1,26 @@
+package openf1
+
+// CarData is a single /car_data sample, recorded at roughly 3.7 Hz per driver.
+type CarData struct {
+	Date         string `json:"date"`
+	DriverNumber int    `json:"driver_number"`
+	MeetingKey   int    `json:"meeting_key"`
+	SessionKey   int    `json:"session_key"`
+	Speed        int    `json:"speed"`
+	RPM          int    `json:"rpm"`
+	NGear        int    `json:"n_gear"`
+	Throttle     int    `json:"throttle"`
+	Brake        int    `json:"brake"`
+	DRS          int    `json:"drs"`
+}
+
+// Location is a single /location sample giving a car's position on track.
+type Location struct {
+	Date         string `json:"date"`
+	DriverNumber int    `json:"driver_number"`
+	MeetingKey   int    `json:"meeting_key"`
+	SessionKey   int    `json:"session_key"`
+	X            int    `json:"x"`
+	Y            int    `json:"y"`
+	Z            int    `json:"z"`
+}
@@ -0,0 +1,202 @@
+// Package output writes command results as JSON, YAML, CSV or NDJSON for
+// scripts. The result types in schema.go are the documented output: fields
+// may be added, but are never renamed or removed.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	// FormatTable is the human readable text each command prints itself.
+	FormatTable  Format = "table"
+	FormatJSON   Format = "json"
+	FormatYAML   Format = "yaml"
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+var Formats = []Format{FormatTable, FormatJSON, FormatYAML, FormatCSV, FormatNDJSON}
+
+// ParseFormat checks s is a known format. Empty means FormatTable.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatTable, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q (want table, json, yaml, csv or ndjson)", s)
+}
+
+// Lister is implemented by every result. CSV and NDJSON write its items one
+// per line, while JSON and YAML write the whole result.
+type Lister interface {
+	Items() []any
+}
+
+// Write writes result to w in format f, which must not be FormatTable.
+func Write(w io.Writer, f Format, result Lister) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, item := range result.Items() {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		return writeCSV(w, result)
+	default:
+		return fmt.Errorf("output: cannot write %s", f)
+	}
+}
+
+// writeCSV writes a header row from the json names of the items' fields,
+// then a row per item. Nothing is written for an empty list. Items must be
+// flat structs; embedded structs are flattened into the same row.
+func writeCSV(w io.Writer, result Lister) error {
+	cw := csv.NewWriter(w)
+
+	items := result.Items()
+	if len(items) > 0 {
+		if err := cw.Write(columns(reflect.TypeOf(items[0]))); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		if err := cw.Write(values(reflect.ValueOf(item))); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func columns(t reflect.Type) []string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var names []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct {
+			names = append(names, columns(ft)...)
+			continue
+		}
+		names = append(names, fieldName(f))
+	}
+	return names
+}
+
+func values(v reflect.Value) []string {
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	t := v.Type()
+
+	var row []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous && indirect(f.Type).Kind() == reflect.Struct {
+			if fv.Kind() == reflect.Pointer && fv.IsNil() {
+				row = append(row, make([]string, len(columns(f.Type)))...)
+				continue
+			}
+			row = append(row, values(fv)...)
+			continue
+		}
+		row = append(row, format(fv))
+	}
+	return row
+}
+
+func format(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = format(v.Index(i))
+		}
+		return strings.Join(parts, ";")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
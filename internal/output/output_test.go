@@ -0,0 +1,112 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+)
+
+func testWeekend() WeekendResult {
+	now := time.Date(2023, 7, 30, 14, 0, 0, 0, time.UTC)
+	offset := 2 * time.Hour
+	return WeekendResult{
+		CountryName: "Belgium",
+		Year:        "2023",
+		Sessions: []Session{
+			NewSession(domain.Session{SessionKey: 9140, SessionName: "Sprint", CountryName: "Belgium", DateStart: now.Add(-24 * time.Hour), DateEnd: now.Add(-23 * time.Hour), GMTOffset: &offset}, now),
+			NewSession(domain.Session{SessionKey: 9141, SessionName: "Race", CountryName: "Belgium", DateStart: now.Add(-time.Hour)}, now),
+		},
+		Meta: NewMeta("⚠️ stale", false, time.Time{}),
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format   Format
+		expected []string
+	}{
+		{
+			format:   FormatJSON,
+			expected: []string{`"country_name": "Belgium"`, `"state": "Finished"`, `"state": "Live"`, `"date_end": null`, `"gmt_offset": "+02:00"`, `"gmt_offset": null`, `"warning": "⚠️ stale"`, `"cached_at": null`},
+		},
+		{
+			format:   FormatYAML,
+			expected: []string{"country_name: Belgium", "sessions:", "state: Live", "warning: ⚠️ stale"},
+		},
+		{
+			format: FormatCSV,
+			expected: []string{
+				"session_key,session_name,meeting_key,country_name,circuit_name,location,year,date_start,date_end,state,gmt_offset,meeting_name,warning,offline,cached_at\n",
+				"9140,Sprint,0,Belgium,,,0,2023-07-29T14:00:00Z,2023-07-29T15:00:00Z,Finished,+02:00,,⚠️ stale,false,\n",
+				"9141,Race,0,Belgium,,,0,2023-07-30T13:00:00Z,,Live,,,⚠️ stale,false,\n",
+			},
+		},
+		{
+			format:   FormatNDJSON,
+			expected: []string{`{"session_key":9140,`, `"state":"Live","gmt_offset":null,"meeting_name":"","warning":"⚠️ stale","offline":false,"cached_at":null}` + "\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, testWeekend()); err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("expected output to contain %q, got:\n%s", expected, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteNDJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatNDJSON, testWeekend()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a line per session, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var row map[string]any
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Errorf("invalid JSON line %q: %v", line, err)
+		}
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	for _, f := range []Format{FormatCSV, FormatNDJSON} {
+		var buf bytes.Buffer
+		if err := Write(&buf, f, SessionResult{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: expected no output, got %q", f, buf.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	_ = Write(&buf, FormatJSON, SessionResult{})
+	if !strings.Contains(buf.String(), `"session": null`) {
+		t.Errorf("expected a null session, got %s", buf.String())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != FormatTable {
+		t.Errorf("expected table by default, got %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,200 @@
+package output
+
+import (
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+	"github.com/bhopalg/pitwall/internal/cache"
+)
+
+// Session is a session as written by every command.
+type Session struct {
+	SessionKey  int    `json:"session_key" yaml:"session_key"`
+	SessionName string `json:"session_name" yaml:"session_name"`
+	MeetingKey  int    `json:"meeting_key" yaml:"meeting_key"`
+	CountryName string `json:"country_name" yaml:"country_name"`
+	CircuitName string `json:"circuit_name" yaml:"circuit_name"`
+	Location    string `json:"location" yaml:"location"`
+	Year        int    `json:"year" yaml:"year"`
+	// DateStart and DateEnd are UTC. DateEnd is null until OpenF1
+	// publishes it.
+	DateStart time.Time  `json:"date_start" yaml:"date_start"`
+	DateEnd   *time.Time `json:"date_end" yaml:"date_end"`
+	// State is Future, Live or Finished at the time of the command.
+	State domain.SessionState `json:"state" yaml:"state"`
+	// GMTOffset is the circuit's offset from UTC, e.g. "+02:00", or null
+	// when unknown.
+	GMTOffset *string `json:"gmt_offset" yaml:"gmt_offset"`
+	// MeetingName is only set by weekend.
+	MeetingName string `json:"meeting_name" yaml:"meeting_name"`
+}
+
+func NewSession(s domain.Session, now time.Time) Session {
+	return Session{
+		SessionKey:  s.SessionKey,
+		SessionName: s.SessionName,
+		MeetingKey:  s.MeetingKey,
+		CountryName: s.CountryName,
+		CircuitName: s.CircuitName,
+		Location:    s.Location,
+		Year:        s.Year,
+		DateStart:   s.DateStart,
+		DateEnd:     timePtr(s.DateEnd),
+		State:       s.State(now),
+		GMTOffset:   gmtOffset(s.GMTOffset),
+		MeetingName: s.MeetingName,
+	}
+}
+
+func gmtOffset(d *time.Duration) *string {
+	if d == nil {
+		return nil
+	}
+	offset := time.Date(2000, 1, 1, 0, 0, 0, 0, time.FixedZone("", int(*d/time.Second))).Format("-07:00")
+	return &offset
+}
+
+// Meta says how fresh a result is.
+type Meta struct {
+	// Warning is set when the data may be out of date, e.g. it was served
+	// stale from the cache because the API failed.
+	Warning string `json:"warning" yaml:"warning"`
+	// Offline is set when --offline answered from the cache, and CachedAt
+	// is then when the data was cached.
+	Offline  bool       `json:"offline" yaml:"offline"`
+	CachedAt *time.Time `json:"cached_at" yaml:"cached_at"`
+}
+
+func NewMeta(warning string, offline bool, cachedAt time.Time) Meta {
+	return Meta{Warning: warning, Offline: offline, CachedAt: timePtr(cachedAt)}
+}
+
+// SessionResult is written by latest and get_session. Session is null when
+// nothing matched.
+type SessionResult struct {
+	Session *Session `json:"session" yaml:"session"`
+	Meta    `yaml:",inline"`
+}
+
+// sessionRow is a session with its result's Meta, for CSV and NDJSON.
+type sessionRow struct {
+	Session
+	Meta
+}
+
+func (r SessionResult) Items() []any {
+	if r.Session == nil {
+		return nil
+	}
+	return []any{sessionRow{*r.Session, r.Meta}}
+}
+
+// WeekendResult is written by weekend. Sessions are in chronological
+// order; Meetings groups them by meeting and day.
+type WeekendResult struct {
+	CountryName string           `json:"country_name" yaml:"country_name"`
+	Year        string           `json:"year" yaml:"year"`
+	Sessions    []Session        `json:"sessions" yaml:"sessions"`
+	Meetings    []WeekendMeeting `json:"meetings" yaml:"meetings"`
+	Meta        `yaml:",inline"`
+}
+
+// WeekendMeeting is one meeting of a weekend. Format is conventional,
+// sprint or testing.
+type WeekendMeeting struct {
+	MeetingKey  int          `json:"meeting_key" yaml:"meeting_key"`
+	MeetingName string       `json:"meeting_name" yaml:"meeting_name"`
+	Format      string       `json:"format" yaml:"format"`
+	Days        []WeekendDay `json:"days" yaml:"days"`
+}
+
+// WeekendDay lists the sessions starting on Date, a YYYY-MM-DD date in the
+// zone times are shown in.
+type WeekendDay struct {
+	Date        string `json:"date" yaml:"date"`
+	SessionKeys []int  `json:"session_keys" yaml:"session_keys"`
+}
+
+func (r WeekendResult) Items() []any {
+	items := make([]any, len(r.Sessions))
+	for i, s := range r.Sessions {
+		items[i] = sessionRow{s, r.Meta}
+	}
+	return items
+}
+
+// RemindResult is written by remind. Session is null when nothing is
+// upcoming.
+type RemindResult struct {
+	Session *Session `json:"session" yaml:"session"`
+	// Remind is whether Session starts within ThresholdMinutes.
+	Remind           bool  `json:"remind" yaml:"remind"`
+	ThresholdMinutes int   `json:"threshold_minutes" yaml:"threshold_minutes"`
+	StartsInSeconds  int64 `json:"starts_in_seconds" yaml:"starts_in_seconds"`
+	Meta             `yaml:",inline"`
+}
+
+type remindRow struct {
+	Session
+	Remind           bool  `json:"remind"`
+	ThresholdMinutes int   `json:"threshold_minutes"`
+	StartsInSeconds  int64 `json:"starts_in_seconds"`
+	Meta
+}
+
+func (r RemindResult) Items() []any {
+	if r.Session == nil {
+		return nil
+	}
+	return []any{remindRow{*r.Session, r.Remind, r.ThresholdMinutes, r.StartsInSeconds, r.Meta}}
+}
+
+// CacheInfoResult is written by cache info.
+type CacheInfoResult struct {
+	Location string       `json:"location" yaml:"location"`
+	Entries  []CacheEntry `json:"entries" yaml:"entries"`
+}
+
+type CacheEntry struct {
+	Key        string    `json:"key" yaml:"key"`
+	File       string    `json:"file" yaml:"file"`
+	CreatedAt  time.Time `json:"created_at" yaml:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at" yaml:"expires_at"`
+	AccessedAt time.Time `json:"accessed_at" yaml:"accessed_at"`
+	Stale      bool      `json:"stale" yaml:"stale"`
+	// Quarantined entries were found corrupt and are no longer served.
+	Quarantined bool `json:"quarantined" yaml:"quarantined"`
+	// Size is the size on disk; RawSize is before compression.
+	Size    int64 `json:"size" yaml:"size"`
+	RawSize int64 `json:"raw_size" yaml:"raw_size"`
+}
+
+func NewCacheEntry(e cache.InfoEntry) CacheEntry {
+	return CacheEntry{
+		Key:         e.Key,
+		File:        e.File,
+		CreatedAt:   e.CreatedAt,
+		ExpiresAt:   e.ExpiresAt,
+		AccessedAt:  e.AccessedAt,
+		Stale:       e.IsStale,
+		Quarantined: e.Corrupt,
+		Size:        e.Size,
+		RawSize:     e.RawSize,
+	}
+}
+
+func (r CacheInfoResult) Items() []any {
+	items := make([]any, len(r.Entries))
+	for i, e := range r.Entries {
+		items[i] = e
+	}
+	return items
+}
+
+// timePtr returns nil for the zero time, so it is written as null.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,134 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bhopalg/pitwall/utils"
+)
+
+// teamColours are the teams' OpenF1 colours, keyed by lower case name and
+// common short names.
+var teamColours = map[string]string{
+	"red bull racing": "#3671C6",
+	"red bull":        "#3671C6",
+	"ferrari":         "#E8002D",
+	"mercedes":        "#27F4D2",
+	"mclaren":         "#FF8000",
+	"aston martin":    "#229971",
+	"alpine":          "#FF87BC",
+	"williams":        "#64C4FF",
+	"rb":              "#6692FF",
+	"racing bulls":    "#6692FF",
+	"kick sauber":     "#52E252",
+	"sauber":          "#52E252",
+	"haas f1 team":    "#B6BABD",
+	"haas":            "#B6BABD",
+}
+
+// TeamColour returns a team's colour as #RRGGBB, or "" for an unknown team.
+func TeamColour(team string) string {
+	return teamColours[strings.ToLower(strings.TrimSpace(team))]
+}
+
+// NewTemplate parses a --format template. Besides the text/template
+// builtins it provides:
+//
+//	localtime TIME          "Sun 30 Jul 15:00 CEST" in loc
+//	in ZONE TIME            TIME in an IANA zone, e.g. in "Asia/Tokyo"
+//	date LAYOUT TIME        TIME formatted with a Go layout, e.g. date "15:04"
+//	relative TIME           "in 2h 5m" or "1h 10m ago", from now
+//	duration D              "1d 2h 3m" for a time.Duration or seconds
+//	teamcolour NAME         a team's colour as #RRGGBB
+//
+// TIME may be a time.Time or *time.Time; nil and zero times give "".
+func NewTemplate(text string, now time.Time, loc *time.Location) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"localtime": func(v any) (string, error) {
+			t, err := toTime(v)
+			if err != nil || t.IsZero() {
+				return "", err
+			}
+			return t.In(loc).Format("Mon 02 Jan 15:04 MST"), nil
+		},
+		"in": func(zone string, v any) (time.Time, error) {
+			t, err := toTime(v)
+			if err != nil {
+				return time.Time{}, err
+			}
+			z, err := time.LoadLocation(zone)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return t.In(z), nil
+		},
+		"date": func(layout string, v any) (string, error) {
+			t, err := toTime(v)
+			if err != nil || t.IsZero() {
+				return "", err
+			}
+			return t.Format(layout), nil
+		},
+		"relative": func(v any) (string, error) {
+			t, err := toTime(v)
+			if err != nil || t.IsZero() {
+				return "", err
+			}
+			d := t.Sub(now).Round(time.Minute)
+			if d < 0 {
+				return utils.FormatDuration(-d) + " ago", nil
+			}
+			return "in " + utils.FormatDuration(d), nil
+		},
+		"duration": func(v any) (string, error) {
+			switch d := v.(type) {
+			case time.Duration:
+				return utils.FormatDuration(d), nil
+			case int:
+				return utils.FormatDuration(time.Duration(d) * time.Second), nil
+			case int64:
+				return utils.FormatDuration(time.Duration(d) * time.Second), nil
+			case float64:
+				return utils.FormatDuration(time.Duration(d * float64(time.Second))), nil
+			default:
+				return "", fmt.Errorf("duration: unsupported type %T", v)
+			}
+		},
+		"teamcolour": TeamColour,
+	}
+
+	return template.New("format").Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// WriteTemplate executes tmpl once per item of result, each followed by a
+// newline.
+func WriteTemplate(w io.Writer, tmpl *template.Template, result Lister) error {
+	for _, item := range result.Items() {
+		if err := tmpl.Execute(w, item); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, nil
+		}
+		return *t, nil
+	case nil:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, fmt.Errorf("expected a time, got %T", v)
+	}
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTemplate(t *testing.T) {
+	now := time.Date(2023, 7, 30, 14, 0, 0, 0, time.UTC)
+	paris, _ := time.LoadLocation("Europe/Paris")
+
+	tests := []struct {
+		name     string
+		format   string
+		result   Lister
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "fields and localtime",
+			format:   "{{.SessionName}} {{.DateStart | localtime}}",
+			result:   testWeekend(),
+			expected: "Sprint Sat 29 Jul 16:00 CEST\nRace Sun 30 Jul 15:00 CEST\n",
+		},
+		{
+			name:     "relative",
+			format:   "{{.SessionName}} {{.DateStart | relative}}",
+			result:   testWeekend(),
+			expected: "Sprint 1d 0h 0m ago\nRace 1h 0m ago\n",
+		},
+		{
+			name:     "in and date",
+			format:   `{{.DateStart | in "Asia/Tokyo" | date "15:04 MST"}}`,
+			result:   testWeekend(),
+			expected: "23:00 JST\n22:00 JST\n",
+		},
+		{
+			name:     "null end time",
+			format:   "[{{.DateEnd | localtime}}]",
+			result:   SessionResult{Session: &testWeekend().Sessions[1]},
+			expected: "[]\n",
+		},
+		{
+			name:     "duration",
+			format:   "{{.SessionName}} in {{.StartsInSeconds | duration}}",
+			result:   RemindResult{Session: &testWeekend().Sessions[1], StartsInSeconds: 93784},
+			expected: "Race in 1d 2h 3m\n",
+		},
+		{
+			name:     "teamcolour",
+			format:   `{{teamcolour "McLaren"}}{{teamcolour "Nope"}}`,
+			result:   SessionResult{Session: &Session{}},
+			expected: "#FF8000\n",
+		},
+		{
+			name:    "unknown field",
+			format:  "{{.Driver}}",
+			result:  testWeekend(),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := NewTemplate(tt.format, now, paris)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			err = WriteTemplate(&buf, tmpl, tt.result)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && buf.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+
+	if _, err := NewTemplate("{{.SessionName", now, paris); err == nil {
+		t.Error("expected parse error")
+	}
+}
@@ -0,0 +1,90 @@
+// Package refresh runs stale-while-revalidate cache refreshes in the
+// background with bounded concurrency.
+package refresh
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type Refresher struct {
+	slots   chan struct{}
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending map[string]bool
+	wg      sync.WaitGroup
+}
+
+// New returns a Refresher running at most workers refreshes at once, each
+// limited to timeout.
+func New(workers int, timeout time.Duration) *Refresher {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Refresher{
+		slots:   make(chan struct{}, workers),
+		timeout: timeout,
+		pending: make(map[string]bool),
+	}
+}
+
+// Go refreshes key in the background by calling fn. It does nothing and
+// returns false if key is already being refreshed or every worker is busy;
+// the stale entry stays and a later request will try again.
+//
+// fn gets a context that keeps ctx's values but not its cancellation, since
+// the request that triggered the refresh will usually finish first.
+func (r *Refresher) Go(ctx context.Context, key string, fn func(ctx context.Context) error) bool {
+	r.mu.Lock()
+	if r.pending[key] {
+		r.mu.Unlock()
+		return false
+	}
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		r.mu.Unlock()
+		return false
+	}
+	r.pending[key] = true
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.pending, key)
+			r.mu.Unlock()
+			<-r.slots
+			r.wg.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+		defer cancel()
+
+		if err := fn(ctx); err != nil {
+			log.Printf("refresh %s: %v", key, err)
+		}
+	}()
+	return true
+}
+
+// Flush waits for running refreshes to finish or for ctx to be done. CLI
+// commands call it before exiting so refreshed data is not lost.
+func (r *Refresher) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
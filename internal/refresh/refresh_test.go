@@ -0,0 +1,87 @@
+package refresh
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefresher(t *testing.T) {
+	t.Run("Runs once per key and flushes", func(t *testing.T) {
+		r := New(2, time.Second)
+		var calls atomic.Int32
+		release := make(chan struct{})
+
+		fn := func(ctx context.Context) error {
+			calls.Add(1)
+			<-release
+			return nil
+		}
+
+		if !r.Go(context.Background(), "latest", fn) {
+			t.Fatal("expected first refresh to start")
+		}
+		if r.Go(context.Background(), "latest", fn) {
+			t.Error("expected duplicate refresh to be skipped")
+		}
+
+		close(release)
+		if err := r.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("expected 1 refresh, got %d", calls.Load())
+		}
+
+		if !r.Go(context.Background(), "latest", func(ctx context.Context) error { return nil }) {
+			t.Error("expected key to be refreshable again after finishing")
+		}
+		r.Flush(context.Background())
+	})
+
+	t.Run("Bounded by workers", func(t *testing.T) {
+		r := New(1, time.Second)
+		release := make(chan struct{})
+
+		r.Go(context.Background(), "a", func(ctx context.Context) error { <-release; return nil })
+		if r.Go(context.Background(), "b", func(ctx context.Context) error { return nil }) {
+			t.Error("expected refresh to be dropped while all workers are busy")
+		}
+
+		close(release)
+		r.Flush(context.Background())
+	})
+
+	t.Run("Survives the caller's cancellation", func(t *testing.T) {
+		r := New(1, time.Second)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var finished atomic.Bool
+		r.Go(ctx, "k", func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			finished.Store(ctx.Err() == nil)
+			return nil
+		})
+		cancel()
+
+		r.Flush(context.Background())
+		if !finished.Load() {
+			t.Error("expected refresh context to outlive the caller's")
+		}
+	})
+
+	t.Run("Flush gives up when its context ends", func(t *testing.T) {
+		r := New(1, time.Second)
+		release := make(chan struct{})
+		defer close(release)
+
+		r.Go(context.Background(), "k", func(ctx context.Context) error { <-release; return nil })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := r.Flush(ctx); err == nil {
+			t.Error("expected flush to time out")
+		}
+	})
+}
@@ -2,17 +2,25 @@ package getsession
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/bhopalg/pitwall/domain"
 	"github.com/bhopalg/pitwall/internal/cache"
 	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/refresh"
+	"github.com/bhopalg/pitwall/internal/singleflight"
 	"github.com/bhopalg/pitwall/utils"
 )
 
 type GetSessionResponse struct {
 	Session *domain.Session
 	Warning string
+	// Offline responses come from the cache only; CachedAt is when the
+	// data was cached, if known.
+	Offline  bool
+	CachedAt time.Time
 }
 
 type GetSessionSessionProvider interface {
@@ -22,17 +30,75 @@ type GetSessionSessionProvider interface {
 type GetSessionService struct {
 	openf1Client GetSessionSessionProvider
 	cache        cache.Cache
+	ttl          cache.TTLPolicy
+	now          func() time.Time
+	flight       singleflight.Group
+	refresher    *refresh.Refresher
+	offline      bool
 }
 
-func New(openf1Client GetSessionSessionProvider, cache cache.Cache) *GetSessionService {
-	return &GetSessionService{openf1Client: openf1Client, cache: cache}
+type Option func(*GetSessionService)
+
+func WithTTLPolicy(p cache.TTLPolicy) Option {
+	return func(s *GetSessionService) {
+		s.ttl = p
+	}
+}
+
+// WithRefresher enables stale-while-revalidate: stale cache entries are
+// returned immediately and refreshed in the background by r.
+func WithRefresher(r *refresh.Refresher) Option {
+	return func(s *GetSessionService) {
+		s.refresher = r
+	}
+}
+
+// WithOffline answers from the cache only, whether entries are fresh or
+// stale, and never calls the API.
+func WithOffline() Option {
+	return func(s *GetSessionService) {
+		s.offline = true
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(s *GetSessionService) {
+		s.now = now
+	}
+}
+
+func New(openf1Client GetSessionSessionProvider, c cache.Cache, opts ...Option) *GetSessionService {
+	s := &GetSessionService{
+		openf1Client: openf1Client,
+		cache:        c,
+		ttl:          cache.DefaultTTLPolicy(),
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CacheKey is the key a session is cached under, shared with cache warm.
+func CacheKey(country_name, session_name, year string) string {
+	return "getsession:" + country_name + ":" + year + ":" + session_name
 }
 
 func (s *GetSessionService) GetSession(ctx context.Context, country_name, session_name, year string) (GetSessionResponse, error) {
-	cacheKey := "getsession:" + country_name + ":" + year + ":" + session_name
+	cacheKey := CacheKey(country_name, session_name, year)
+
+	if s.offline {
+		return s.cached(cacheKey)
+	}
+
 	var cachedSessions []domain.Session
 
-	found, isStale, _ := s.cache.Get(cacheKey, &cachedSessions)
+	found, isStale, err := s.cache.Get(cacheKey, &cachedSessions)
+	if err != nil {
+		log.Printf("cache: %v", err)
+	}
+	found = found && len(cachedSessions) > 0
 
 	if found && !isStale {
 		return GetSessionResponse{
@@ -40,7 +106,22 @@ func (s *GetSessionService) GetSession(ctx context.Context, country_name, sessio
 		}, nil
 	}
 
-	session, err := s.openf1Client.GetSession(ctx, country_name, session_name, year)
+	if found && s.refresher != nil {
+		s.refresher.Go(ctx, cacheKey, func(ctx context.Context) error {
+			_, err, _ := s.flight.Do(ctx, cacheKey, func(ctx context.Context) (any, error) {
+				return s.fetch(ctx, cacheKey, country_name, session_name, year)
+			})
+			return err
+		})
+		return GetSessionResponse{
+			Session: &cachedSessions[0],
+			Warning: "⚠️ Showing stale cached data while it refreshes in the background.",
+		}, nil
+	}
+
+	v, err, _ := s.flight.Do(ctx, cacheKey, func(ctx context.Context) (any, error) {
+		return s.fetch(ctx, cacheKey, country_name, session_name, year)
+	})
 	if err != nil && found {
 		return GetSessionResponse{
 			Session: &cachedSessions[0],
@@ -52,13 +133,57 @@ func (s *GetSessionService) GetSession(ctx context.Context, country_name, sessio
 		return GetSessionResponse{}, err
 	}
 
+	fetched := v.(*domain.Session)
+	if fetched == nil {
+		return GetSessionResponse{}, nil
+	}
+
+	// Concurrent callers share the fetched session, so each gets its own copy.
+	session := *fetched
+	return GetSessionResponse{
+		Session: &session,
+	}, nil
+}
+
+// fetch loads the session from the API and caches it. Concurrent calls for
+// the same key share one fetch.
+func (s *GetSessionService) fetch(ctx context.Context, cacheKey, country_name, session_name, year string) (*domain.Session, error) {
+	session, err := s.openf1Client.GetSession(ctx, country_name, session_name, year)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, nil
+	}
+
 	mappedSession, err := utils.MapToDomain(session)
 	if err != nil {
-		return GetSessionResponse{}, err
+		return nil, err
+	}
+
+	if err := s.cache.Set(cacheKey, []domain.Session{*mappedSession}, s.ttl.For(s.now(), *mappedSession)); err != nil {
+		log.Printf("cache: %v", err)
+	}
+	return mappedSession, nil
+}
+
+// cached answers from the cache alone, for offline mode.
+func (s *GetSessionService) cached(cacheKey string) (GetSessionResponse, error) {
+	var cachedSessions []domain.Session
+
+	meta, found, err := cache.GetMeta(s.cache, cacheKey, &cachedSessions)
+	if err != nil {
+		log.Printf("cache: %v", err)
+	}
+	if !found || len(cachedSessions) == 0 {
+		return GetSessionResponse{}, fmt.Errorf("%w: %s is not cached", openf1.ErrOffline, cacheKey)
 	}
 
-	_ = s.cache.Set(cacheKey, session, 24*time.Hour)
 	return GetSessionResponse{
-		Session: mappedSession,
+		Session:  &cachedSessions[0],
+		Warning:  utils.OfflineWarning(meta.CreatedAt, s.now()),
+		Offline:  true,
+		CachedAt: meta.CreatedAt,
 	}, nil
 }
@@ -3,6 +3,8 @@ package getsession
 import (
 	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,11 +17,12 @@ type mockCache struct {
 	storage map[string]interface{}
 	found   bool
 	isStale bool
+	err     error
 }
 
 func (m *mockCache) Get(key string, target interface{}) (bool, bool, error) {
 	if !m.found {
-		return false, false, nil
+		return false, false, m.err
 	}
 	if data, ok := m.storage[key]; ok {
 		if sessions, ok := data.([]domain.Session); ok {
@@ -59,6 +62,7 @@ func TestGetSession(t *testing.T) {
 		mockErr         error
 		cacheFound      bool
 		cacheStale      bool
+		cacheErr        error
 		expectedError   bool
 		expectedWarning string
 		expectRepoCall  bool
@@ -86,6 +90,18 @@ func TestGetSession(t *testing.T) {
 			expectedError:  false,
 			expectRepoCall: true,
 		},
+		{
+			name: "Cache Error - Falls back to API",
+			mockResp: &openf1.Session{
+				SessionName: "Race",
+				DateStart:   "2024-03-02T15:00:00Z",
+				DateEnd:     "2024-03-02T17:00:00Z",
+			},
+			cacheFound:     false,
+			cacheErr:       &cache.Error{Op: "get", Key: "getsession:Bahrain:2024:Race", Kind: cache.ErrCorrupt},
+			expectedError:  false,
+			expectRepoCall: true,
+		},
 		{
 			name:            "Stale Cache + API Failure (Fallback)",
 			mockErr:         errors.New("API down"),
@@ -118,6 +134,7 @@ func TestGetSession(t *testing.T) {
 				storage: make(map[string]interface{}),
 				found:   tc.cacheFound,
 				isStale: tc.cacheStale,
+				err:     tc.cacheErr,
 			}
 
 			if tc.cacheFound {
@@ -146,3 +163,39 @@ func TestGetSession(t *testing.T) {
 		})
 	}
 }
+
+type countingClient struct {
+	calls atomic.Int32
+}
+
+func (c *countingClient) GetSession(ctx context.Context, country, session, year string) (*openf1.Session, error) {
+	c.calls.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return &openf1.Session{
+		SessionName: session,
+		DateStart:   "2024-03-02T15:00:00Z",
+		DateEnd:     "2024-03-02T17:00:00Z",
+	}, nil
+}
+
+func TestGetSessionSingleflight(t *testing.T) {
+	client := &countingClient{}
+	s := New(client, &mockCache{storage: make(map[string]interface{})})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.GetSession(context.Background(), "Bahrain", "Race", "2024")
+			if err != nil || res.Session == nil || res.Session.SessionName != "Race" {
+				t.Errorf("unexpected response %+v, err %v", res, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := client.calls.Load(); got != 1 {
+		t.Errorf("expected concurrent requests to share 1 API call, got %d", got)
+	}
+}
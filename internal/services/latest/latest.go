@@ -2,17 +2,25 @@ package latest
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/bhopalg/pitwall/domain"
 	"github.com/bhopalg/pitwall/internal/cache"
 	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/refresh"
+	"github.com/bhopalg/pitwall/internal/singleflight"
 	"github.com/bhopalg/pitwall/utils"
 )
 
 type LatestResponse struct {
 	Session *domain.Session
 	Warning string
+	// Offline responses come from the cache only; CachedAt is when the
+	// data was cached, if known.
+	Offline  bool
+	CachedAt time.Time
 }
 
 type NextSessionProivder interface {
@@ -22,17 +30,70 @@ type NextSessionProivder interface {
 type NextSessionService struct {
 	openf1Client NextSessionProivder
 	cache        cache.Cache
+	ttl          cache.TTLPolicy
+	now          func() time.Time
+	flight       singleflight.Group
+	refresher    *refresh.Refresher
+	offline      bool
 }
 
-func New(openf1Client NextSessionProivder, cache cache.Cache) *NextSessionService {
-	return &NextSessionService{openf1Client: openf1Client, cache: cache}
+type Option func(*NextSessionService)
+
+func WithTTLPolicy(p cache.TTLPolicy) Option {
+	return func(n *NextSessionService) {
+		n.ttl = p
+	}
+}
+
+// WithRefresher enables stale-while-revalidate: stale cache entries are
+// returned immediately and refreshed in the background by r.
+func WithRefresher(r *refresh.Refresher) Option {
+	return func(n *NextSessionService) {
+		n.refresher = r
+	}
+}
+
+// WithOffline answers from the cache only, whether entries are fresh or
+// stale, and never calls the API.
+func WithOffline() Option {
+	return func(n *NextSessionService) {
+		n.offline = true
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(n *NextSessionService) {
+		n.now = now
+	}
+}
+
+func New(openf1Client NextSessionProivder, c cache.Cache, opts ...Option) *NextSessionService {
+	n := &NextSessionService{
+		openf1Client: openf1Client,
+		cache:        c,
+		ttl:          cache.DefaultTTLPolicy(),
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
 }
 
 func (n *NextSessionService) Next(ctx context.Context) (LatestResponse, error) {
 	cacheKey := "latest"
+
+	if n.offline {
+		return n.cached(cacheKey)
+	}
+
 	var cachedSessions []domain.Session
 
-	found, isStale, _ := n.cache.Get(cacheKey, &cachedSessions)
+	found, isStale, err := n.cache.Get(cacheKey, &cachedSessions)
+	if err != nil {
+		log.Printf("cache: %v", err)
+	}
+	found = found && len(cachedSessions) > 0
 
 	if found && !isStale {
 		return LatestResponse{
@@ -40,7 +101,22 @@ func (n *NextSessionService) Next(ctx context.Context) (LatestResponse, error) {
 		}, nil
 	}
 
-	session, err := n.openf1Client.Next(ctx)
+	if found && n.refresher != nil {
+		n.refresher.Go(ctx, cacheKey, func(ctx context.Context) error {
+			_, err, _ := n.flight.Do(ctx, cacheKey, func(ctx context.Context) (any, error) {
+				return n.fetch(ctx, cacheKey)
+			})
+			return err
+		})
+		return LatestResponse{
+			Session: &cachedSessions[0],
+			Warning: "⚠️ Showing stale cached data while it refreshes in the background.",
+		}, nil
+	}
+
+	v, err, _ := n.flight.Do(ctx, cacheKey, func(ctx context.Context) (any, error) {
+		return n.fetch(ctx, cacheKey)
+	})
 	if err != nil && found {
 		return LatestResponse{
 			Session: &cachedSessions[0],
@@ -52,13 +128,57 @@ func (n *NextSessionService) Next(ctx context.Context) (LatestResponse, error) {
 		return LatestResponse{}, err
 	}
 
+	fetched := v.(*domain.Session)
+	if fetched == nil {
+		return LatestResponse{}, nil
+	}
+
+	// Concurrent callers share the fetched session, so each gets its own copy.
+	session := *fetched
+	return LatestResponse{
+		Session: &session,
+	}, nil
+}
+
+// fetch loads the session from the API and caches it. Concurrent calls for
+// the same key share one fetch.
+func (n *NextSessionService) fetch(ctx context.Context, cacheKey string) (*domain.Session, error) {
+	session, err := n.openf1Client.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, nil
+	}
+
 	mappedSession, err := utils.MapToDomain(session)
 	if err != nil {
-		return LatestResponse{}, err
+		return nil, err
+	}
+
+	if err := n.cache.Set(cacheKey, []domain.Session{*mappedSession}, n.ttl.ForLatest(n.now(), *mappedSession)); err != nil {
+		log.Printf("cache: %v", err)
+	}
+	return mappedSession, nil
+}
+
+// cached answers from the cache alone, for offline mode.
+func (n *NextSessionService) cached(cacheKey string) (LatestResponse, error) {
+	var cachedSessions []domain.Session
+
+	meta, found, err := cache.GetMeta(n.cache, cacheKey, &cachedSessions)
+	if err != nil {
+		log.Printf("cache: %v", err)
+	}
+	if !found || len(cachedSessions) == 0 {
+		return LatestResponse{}, fmt.Errorf("%w: %s is not cached", openf1.ErrOffline, cacheKey)
 	}
 
-	_ = n.cache.Set(cacheKey, session, 24*time.Hour)
 	return LatestResponse{
-		Session: mappedSession,
+		Session:  &cachedSessions[0],
+		Warning:  utils.OfflineWarning(meta.CreatedAt, n.now()),
+		Offline:  true,
+		CachedAt: meta.CreatedAt,
 	}, nil
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/bhopalg/pitwall/domain"
 	"github.com/bhopalg/pitwall/internal/cache"
 	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/refresh"
 )
 
 type mockCache struct {
@@ -145,3 +146,40 @@ func TestNext(t *testing.T) {
 		})
 	}
 }
+
+func TestNextStaleWhileRevalidate(t *testing.T) {
+	stale := domain.Session{SessionKey: 1, SessionName: "Race"}
+	mc := &mockCache{
+		storage: map[string]interface{}{"latest": []domain.Session{stale}},
+		found:   true,
+		isStale: true,
+	}
+
+	release := make(chan struct{})
+	client := &mockClient{fn: func(ctx context.Context) (*openf1.Session, error) {
+		<-release
+		return &openf1.Session{SessionKey: 2, SessionName: "Race", DateStart: "2024-01-01T00:00:00Z", DateEnd: "2024-01-01T02:00:00Z"}, nil
+	}}
+
+	r := refresh.New(1, time.Second)
+	service := New(client, mc, WithRefresher(r))
+
+	res, err := service.Next(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Session == nil || res.Session.SessionKey != stale.SessionKey {
+		t.Fatalf("expected stale session to be returned immediately, got %+v", res.Session)
+	}
+	if res.Warning == "" {
+		t.Error("expected a stale warning")
+	}
+
+	close(release)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if !client.called {
+		t.Error("expected a background refresh to call the API")
+	}
+}
@@ -0,0 +1,306 @@
+// Package warm fills the cache with a whole season so later lookups are
+// instant and work offline.
+package warm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+	"github.com/bhopalg/pitwall/internal/cache"
+	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/services/getsession"
+	"github.com/bhopalg/pitwall/internal/services/weekend"
+)
+
+// Extra per-session datasets that can be warmed alongside the schedule.
+const (
+	IncludeLaps    = "laps"
+	IncludeStints  = "stints"
+	IncludeResults = "results"
+)
+
+var includeTypes = map[string]func() any{
+	IncludeLaps:    func() any { return new([]openf1.Lap) },
+	IncludeStints:  func() any { return new([]openf1.Stint) },
+	IncludeResults: func() any { return new([]openf1.SessionResult) },
+}
+
+// Codecs returns the cache codecs for the per-session datasets, by key
+// prefix.
+func Codecs() map[string]cache.Codec {
+	codecs := make(map[string]cache.Codec, len(includeTypes))
+	for name, newFn := range includeTypes {
+		codecs[name+":"] = cache.Codec{Type: name, Version: 1, New: newFn}
+	}
+	return codecs
+}
+
+// Key is the key a session's laps, stints or results are cached under.
+func Key(include string, sessionKey int) string {
+	return include + ":" + strconv.Itoa(sessionKey)
+}
+
+type SeasonProvider interface {
+	weekend.WeekendProvider
+	GetMeetings(ctx context.Context, year string) ([]openf1.Meeting, error)
+	GetLaps(ctx context.Context, sessionKey int) ([]openf1.Lap, error)
+	GetStints(ctx context.Context, sessionKey int) ([]openf1.Stint, error)
+	GetSessionResults(ctx context.Context, sessionKey int) ([]openf1.SessionResult, error)
+}
+
+type Status string
+
+const (
+	StatusFetched Status = "fetched"
+	// StatusSkipped means the entry was already cached and fresh, which is
+	// how an interrupted warm resumes.
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+// Progress is reported after each key is warmed. Done and Total count the
+// keys in the current pass: weekends first, then per-session data.
+type Progress struct {
+	Done   int
+	Total  int
+	Key    string
+	Status Status
+	Err    error
+}
+
+type Result struct {
+	Fetched int
+	Skipped int
+	Failed  int
+}
+
+type WarmService struct {
+	openf1Client SeasonProvider
+	cache        cache.Cache
+	weekend      *weekend.WeekendService
+	ttl          cache.TTLPolicy
+	now          func() time.Time
+	workers      int
+	progress     func(Progress)
+
+	mu     sync.Mutex
+	result Result
+}
+
+type Option func(*WarmService)
+
+func WithTTLPolicy(p cache.TTLPolicy) Option {
+	return func(w *WarmService) {
+		w.ttl = p
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(w *WarmService) {
+		w.now = now
+	}
+}
+
+// WithWorkers sets how many keys are fetched at once.
+func WithWorkers(n int) Option {
+	return func(w *WarmService) {
+		w.workers = max(n, 1)
+	}
+}
+
+func WithProgress(fn func(Progress)) Option {
+	return func(w *WarmService) {
+		w.progress = fn
+	}
+}
+
+func New(openf1Client SeasonProvider, c cache.Cache, opts ...Option) *WarmService {
+	w := &WarmService{
+		openf1Client: openf1Client,
+		cache:        c,
+		ttl:          cache.DefaultTTLPolicy(),
+		now:          time.Now,
+		workers:      4,
+		progress:     func(Progress) {},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.weekend = weekend.New(openf1Client, c, weekend.WithTTLPolicy(w.ttl), weekend.WithClock(w.now))
+	return w
+}
+
+// Warm caches every weekend of year, every session in it under the keys
+// getsession reads, and the include datasets for each session. Keys that
+// are already fresh are skipped, so running it again after an interruption
+// picks up where it stopped.
+func (w *WarmService) Warm(ctx context.Context, year string, include []string) (Result, error) {
+	for _, name := range include {
+		if _, ok := includeTypes[name]; !ok {
+			return Result{}, fmt.Errorf("unknown dataset %q (want laps, stints or results)", name)
+		}
+	}
+
+	w.result = Result{}
+
+	meetings, err := w.openf1Client.GetMeetings(ctx, year)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Testing and the grand prix can share a country, and one weekend key
+	// covers both.
+	var countries []string
+	seen := make(map[string]bool)
+	for _, m := range meetings {
+		if !seen[m.CountryName] {
+			seen[m.CountryName] = true
+			countries = append(countries, m.CountryName)
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		sessions []domain.Session
+	)
+	err = w.run(ctx, len(countries), func(i int) (string, Status, error) {
+		key := weekend.CacheKey(countries[i], year)
+		status, weekendSessions, err := w.warmWeekend(ctx, countries[i], year)
+
+		mu.Lock()
+		sessions = append(sessions, weekendSessions...)
+		mu.Unlock()
+		return key, status, err
+	})
+	if err != nil {
+		return w.result, err
+	}
+
+	type job struct {
+		include string
+		session domain.Session
+	}
+	var jobs []job
+	for _, s := range sessions {
+		for _, name := range include {
+			jobs = append(jobs, job{name, s})
+		}
+	}
+
+	err = w.run(ctx, len(jobs), func(i int) (string, Status, error) {
+		key := Key(jobs[i].include, jobs[i].session.SessionKey)
+		status, err := w.warmSessionData(ctx, key, jobs[i].include, jobs[i].session)
+		return key, status, err
+	})
+	return w.result, err
+}
+
+// warmWeekend caches a weekend through the weekend service, then each of
+// its sessions under its getsession key.
+func (w *WarmService) warmWeekend(ctx context.Context, country_name, year string) (Status, []domain.Session, error) {
+	status := StatusFetched
+	if w.fresh(weekend.CacheKey(country_name, year), &[]domain.Session{}) {
+		status = StatusSkipped
+	}
+
+	res, err := w.weekend.Weekend(ctx, country_name, year)
+	if err != nil {
+		return StatusFailed, nil, err
+	}
+	if res.Warning != "" {
+		return StatusFailed, nil, errors.New(res.Warning)
+	}
+	sessions := weekend.Sessions(res.Meetings)
+
+	now := w.now()
+	for _, s := range sessions {
+		key := getsession.CacheKey(country_name, s.SessionName, year)
+		if w.fresh(key, &[]domain.Session{}) {
+			continue
+		}
+		if err := w.cache.Set(key, []domain.Session{s}, w.ttl.For(now, s)); err != nil {
+			return StatusFailed, nil, err
+		}
+	}
+	return status, sessions, nil
+}
+
+func (w *WarmService) warmSessionData(ctx context.Context, key, include string, s domain.Session) (Status, error) {
+	if w.fresh(key, includeTypes[include]()) {
+		return StatusSkipped, nil
+	}
+
+	var (
+		data any
+		err  error
+	)
+	switch include {
+	case IncludeLaps:
+		data, err = w.openf1Client.GetLaps(ctx, s.SessionKey)
+	case IncludeStints:
+		data, err = w.openf1Client.GetStints(ctx, s.SessionKey)
+	case IncludeResults:
+		data, err = w.openf1Client.GetSessionResults(ctx, s.SessionKey)
+	}
+	if err != nil {
+		return StatusFailed, err
+	}
+
+	if err := w.cache.Set(key, data, w.ttl.For(w.now(), s)); err != nil {
+		return StatusFailed, err
+	}
+	return StatusFetched, nil
+}
+
+func (w *WarmService) fresh(key string, target any) bool {
+	found, isStale, err := w.cache.Get(key, target)
+	return err == nil && found && !isStale
+}
+
+// run calls fn for indexes 0 to n-1 on a bounded pool of workers, reporting
+// progress after each. It stops starting new work once ctx is done.
+func (w *WarmService) run(ctx context.Context, n int, fn func(i int) (string, Status, error)) error {
+	sem := make(chan struct{}, w.workers)
+	var wg sync.WaitGroup
+	done := 0
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			key, status, err := fn(i)
+
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			switch status {
+			case StatusFetched:
+				w.result.Fetched++
+			case StatusSkipped:
+				w.result.Skipped++
+			case StatusFailed:
+				w.result.Failed++
+			}
+			done++
+			w.progress(Progress{Done: done, Total: n, Key: key, Status: status, Err: err})
+		}()
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,127 @@
+package warm
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+	"github.com/bhopalg/pitwall/internal/cache"
+	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/openf1/openf1test"
+	"github.com/bhopalg/pitwall/internal/services/getsession"
+	"github.com/bhopalg/pitwall/internal/services/weekend"
+)
+
+func newSeason(t *testing.T) *openf1test.Server {
+	t.Helper()
+
+	srv := openf1test.NewServer(t, nil)
+	srv.Add("/meetings",
+		openf1.Meeting{MeetingKey: 1216, MeetingName: "Belgian Grand Prix", CountryName: "Belgium", Year: 2023},
+		openf1.Meeting{MeetingKey: 1217, MeetingName: "Dutch Grand Prix", CountryName: "Netherlands", Year: 2023},
+	)
+	srv.Add("/sessions",
+		openf1.Session{SessionKey: 9140, SessionName: "Sprint", DateStart: "2023-07-29T15:05:00+00:00", DateEnd: "2023-07-29T15:35:00+00:00", CountryName: "Belgium", MeetingKey: 1216, Year: 2023},
+		openf1.Session{SessionKey: 9141, SessionName: "Race", DateStart: "2023-07-30T13:00:00+00:00", DateEnd: "2023-07-30T15:00:00+00:00", CountryName: "Belgium", MeetingKey: 1216, Year: 2023},
+		openf1.Session{SessionKey: 9150, SessionName: "Race", DateStart: "2023-08-27T13:00:00+00:00", DateEnd: "2023-08-27T15:00:00+00:00", CountryName: "Netherlands", MeetingKey: 1217, Year: 2023},
+	)
+	srv.Add("/laps",
+		openf1.Lap{DriverNumber: 1, LapNumber: 1, SessionKey: 9141},
+		openf1.Lap{DriverNumber: 1, LapNumber: 1, SessionKey: 9150},
+	)
+	return srv
+}
+
+func TestWarm(t *testing.T) {
+	srv := newSeason(t)
+	fc := &cache.FileCache{Dir: t.TempDir()}
+
+	var (
+		mu       sync.Mutex
+		progress []Progress
+	)
+	service := New(srv.Client(), fc, WithWorkers(2), WithProgress(func(p Progress) {
+		mu.Lock()
+		progress = append(progress, p)
+		mu.Unlock()
+	}))
+
+	res, err := service.Warm(context.Background(), "2023", []string{IncludeLaps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Fetched != 5 || res.Skipped != 0 || res.Failed != 0 {
+		t.Errorf("expected 2 weekends and 3 lap sets fetched, got %+v", res)
+	}
+	if len(progress) != 5 {
+		t.Errorf("expected progress for every key, got %d", len(progress))
+	}
+
+	var sessions []domain.Session
+	for _, key := range []string{
+		weekend.CacheKey("Belgium", "2023"),
+		getsession.CacheKey("Belgium", "Sprint", "2023"),
+		getsession.CacheKey("Netherlands", "Race", "2023"),
+	} {
+		if found, _, err := fc.Get(key, &sessions); !found || err != nil {
+			t.Errorf("expected %s to be cached, got %v", key, err)
+		}
+	}
+
+	var laps []openf1.Lap
+	if found, _, _ := fc.Get(Key(IncludeLaps, 9141), &laps); !found || len(laps) != 1 {
+		t.Errorf("expected laps for 9141, got %v", laps)
+	}
+
+	t.Run("Resumes by skipping fresh keys", func(t *testing.T) {
+		before := srv.Requests("/sessions") + srv.Requests("/laps")
+
+		res, err := New(srv.Client(), fc).Warm(context.Background(), "2023", []string{IncludeLaps})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Skipped != 5 || res.Fetched != 0 {
+			t.Errorf("expected everything skipped, got %+v", res)
+		}
+		if after := srv.Requests("/sessions") + srv.Requests("/laps"); after != before {
+			t.Errorf("expected no API calls, got %d", after-before)
+		}
+	})
+}
+
+func TestWarmFailures(t *testing.T) {
+	t.Run("Unknown dataset", func(t *testing.T) {
+		srv := newSeason(t)
+		if _, err := New(srv.Client(), &cache.FileCache{Dir: t.TempDir()}).Warm(context.Background(), "2023", []string{"tyres"}); err == nil {
+			t.Error("expected error for unknown dataset")
+		}
+	})
+
+	t.Run("Failed keys are counted and the rest warmed", func(t *testing.T) {
+		srv := newSeason(t)
+		srv.Fail("/laps", http.StatusInternalServerError)
+
+		res, err := New(srv.Client(), &cache.FileCache{Dir: t.TempDir()}).Warm(context.Background(), "2023", []string{IncludeLaps})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Fetched != 2 || res.Failed != 3 {
+			t.Errorf("expected weekends fetched and laps failed, got %+v", res)
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		srv := newSeason(t)
+		srv.SetLatency("/sessions", 50*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := New(srv.Client(), &cache.FileCache{Dir: t.TempDir()}, WithWorkers(1)).Warm(ctx, "2023", nil); err == nil {
+			t.Error("expected cancellation error")
+		}
+	})
+}
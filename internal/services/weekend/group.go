@@ -0,0 +1,118 @@
+package weekend
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bhopalg/pitwall/domain"
+)
+
+// Format is how a meeting's sessions are laid out.
+type Format string
+
+const (
+	FormatConventional Format = "conventional"
+	FormatSprint       Format = "sprint"
+	FormatTesting      Format = "testing"
+)
+
+// Meeting is one meeting of a weekend with its sessions grouped by day.
+type Meeting struct {
+	MeetingKey int
+	// Name is empty when the meeting could not be looked up.
+	Name   string
+	Format Format
+	Days   []Day
+}
+
+// Day is the sessions of a meeting that start on one calendar date.
+type Day struct {
+	// Date is midnight at the start of the day, in the zone the sessions
+	// were grouped in.
+	Date     time.Time
+	Sessions []domain.Session
+}
+
+// Group splits sessions into meetings, and each meeting into the calendar
+// days its sessions start on in the zone returned by zone. Meetings, days
+// and sessions are in chronological order. A country usually holds one
+// meeting a season, but pre-season testing can share it with the grand
+// prix.
+func Group(sessions []domain.Session, zone func(s *domain.Session) *time.Location) []Meeting {
+	sorted := slices.Clone(sessions)
+	slices.SortStableFunc(sorted, func(a, b domain.Session) int {
+		return a.DateStart.Compare(b.DateStart)
+	})
+
+	var meetings []Meeting
+	index := make(map[int]int)
+	for _, s := range sorted {
+		i, ok := index[s.MeetingKey]
+		if !ok {
+			i = len(meetings)
+			index[s.MeetingKey] = i
+			meetings = append(meetings, Meeting{MeetingKey: s.MeetingKey})
+		}
+		m := &meetings[i]
+		if m.Name == "" {
+			m.Name = s.MeetingName
+		}
+
+		start := s.DateStart.In(zone(&s))
+		date := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+		if n := len(m.Days); n > 0 && sameDate(m.Days[n-1].Date, date) {
+			m.Days[n-1].Sessions = append(m.Days[n-1].Sessions, s)
+			continue
+		}
+		m.Days = append(m.Days, Day{Date: date, Sessions: []domain.Session{s}})
+	}
+
+	for i := range meetings {
+		meetings[i].Format = meetingFormat(&meetings[i])
+	}
+	return meetings
+}
+
+// Sessions returns the sessions of meetings in order.
+func Sessions(meetings []Meeting) []domain.Session {
+	var sessions []domain.Session
+	for _, m := range meetings {
+		for _, day := range m.Days {
+			sessions = append(sessions, day.Sessions...)
+		}
+	}
+	return sessions
+}
+
+func sameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// meetingFormat tells testing from race weekends by name, since OpenF1
+// names testing sessions "Day 1" and so on, and sprint weekends by their
+// sprint sessions.
+func meetingFormat(m *Meeting) Format {
+	if strings.Contains(m.Name, "Testing") {
+		return FormatTesting
+	}
+
+	testing, sprint := true, false
+	for _, day := range m.Days {
+		for _, s := range day.Sessions {
+			testing = testing && strings.HasPrefix(s.SessionName, "Day ")
+			sprint = sprint || strings.Contains(s.SessionName, "Sprint")
+		}
+	}
+
+	switch {
+	case testing:
+		return FormatTesting
+	case sprint:
+		return FormatSprint
+	default:
+		return FormatConventional
+	}
+}
@@ -2,12 +2,15 @@ package weekend
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/bhopalg/pitwall/domain"
 	"github.com/bhopalg/pitwall/internal/cache"
 	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/refresh"
+	"github.com/bhopalg/pitwall/internal/singleflight"
 	"github.com/bhopalg/pitwall/utils"
 )
 
@@ -15,39 +18,130 @@ type WeekendProvider interface {
 	GetSessions(ctx context.Context, country_name, year string) (*[]openf1.Session, error)
 }
 
+// MeetingProvider is implemented by clients that can look up a weekend's
+// meetings, which are used to name them. Without it meetings are unnamed.
+type MeetingProvider interface {
+	GetWeekendMeetings(ctx context.Context, country_name, year string) ([]openf1.Meeting, error)
+}
+
 type WeekendResponse struct {
-	Sessions *[]domain.Session
+	// Meetings holds the weekend's sessions grouped by meeting and by day
+	// in the zone set with WithZone.
+	Meetings []Meeting
 	Warning  string
+	// Offline responses come from the cache only; CachedAt is when the
+	// data was cached, if known.
+	Offline  bool
+	CachedAt time.Time
 }
 
 type WeekendService struct {
 	openf1Client WeekendProvider
 	cache        cache.Cache
+	ttl          cache.TTLPolicy
+	now          func() time.Time
+	flight       singleflight.Group
+	refresher    *refresh.Refresher
+	offline      bool
+	zone         func(s *domain.Session) *time.Location
+}
+
+type Option func(*WeekendService)
+
+func WithTTLPolicy(p cache.TTLPolicy) Option {
+	return func(w *WeekendService) {
+		w.ttl = p
+	}
+}
+
+// WithRefresher enables stale-while-revalidate: stale cache entries are
+// returned immediately and refreshed in the background by r.
+func WithRefresher(r *refresh.Refresher) Option {
+	return func(w *WeekendService) {
+		w.refresher = r
+	}
+}
+
+// WithOffline answers from the cache only, whether entries are fresh or
+// stale, and never calls the API.
+func WithOffline() Option {
+	return func(w *WeekendService) {
+		w.offline = true
+	}
+}
+
+// WithZone sets the zone each session's day is worked out in. The default
+// is UTC.
+func WithZone(zone func(s *domain.Session) *time.Location) Option {
+	return func(w *WeekendService) {
+		w.zone = zone
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(w *WeekendService) {
+		w.now = now
+	}
 }
 
-func New(openf1Client WeekendProvider, cache cache.Cache) *WeekendService {
-	return &WeekendService{
+func New(openf1Client WeekendProvider, c cache.Cache, opts ...Option) *WeekendService {
+	w := &WeekendService{
 		openf1Client: openf1Client,
-		cache:        cache,
+		cache:        c,
+		ttl:          cache.DefaultTTLPolicy(),
+		now:          time.Now,
+		zone:         func(*domain.Session) *time.Location { return time.UTC },
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// CacheKey is the key a weekend is cached under, shared with cache warm.
+func CacheKey(country_name, year string) string {
+	return "weekend:" + country_name + ":" + year
 }
 
 func (w *WeekendService) Weekend(ctx context.Context, country_name, year string) (WeekendResponse, error) {
-	cacheKey := "weekend:" + country_name + ":" + year
+	cacheKey := CacheKey(country_name, year)
+
+	if w.offline {
+		return w.cached(cacheKey)
+	}
+
 	var cachedSessions []domain.Session
 
-	found, isStale, _ := w.cache.Get(cacheKey, &cachedSessions)
+	found, isStale, err := w.cache.Get(cacheKey, &cachedSessions)
+	if err != nil {
+		log.Printf("cache: %v", err)
+	}
 
 	if found && !isStale {
 		return WeekendResponse{
-			Sessions: &cachedSessions,
+			Meetings: w.group(cachedSessions),
 		}, nil
 	}
 
-	apiSessions, err := w.openf1Client.GetSessions(ctx, country_name, year)
+	if found && w.refresher != nil {
+		w.refresher.Go(ctx, cacheKey, func(ctx context.Context) error {
+			_, err, _ := w.flight.Do(ctx, cacheKey, func(ctx context.Context) (any, error) {
+				return w.fetch(ctx, cacheKey, country_name, year)
+			})
+			return err
+		})
+		return WeekendResponse{
+			Meetings: w.group(cachedSessions),
+			Warning:  "⚠️ Showing stale cached data while it refreshes in the background.",
+		}, nil
+	}
+
+	v, err, _ := w.flight.Do(ctx, cacheKey, func(ctx context.Context) (any, error) {
+		return w.fetch(ctx, cacheKey, country_name, year)
+	})
 	if err != nil && found {
 		return WeekendResponse{
-			Sessions: &cachedSessions,
+			Meetings: w.group(cachedSessions),
 			Warning:  "⚠️ API unavailable. Showing stale cached data.",
 		}, nil
 	}
@@ -56,6 +150,32 @@ func (w *WeekendService) Weekend(ctx context.Context, country_name, year string)
 		return WeekendResponse{}, err
 	}
 
+	// Concurrent callers share the fetched slice, which Group only copies.
+	sessions := v.([]domain.Session)
+	if len(sessions) == 0 {
+		return WeekendResponse{}, nil
+	}
+
+	return WeekendResponse{Meetings: w.group(sessions)}, nil
+}
+
+// group splits sessions into meetings and days in the service's zone.
+func (w *WeekendService) group(sessions []domain.Session) []Meeting {
+	return Group(sessions, w.zone)
+}
+
+// fetch loads a weekend from the API and caches it. Concurrent calls for the
+// same weekend share one fetch.
+func (w *WeekendService) fetch(ctx context.Context, cacheKey, country_name, year string) ([]domain.Session, error) {
+	apiSessions, err := w.openf1Client.GetSessions(ctx, country_name, year)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiSessions == nil {
+		return nil, nil
+	}
+
 	var sessions []domain.Session
 	for _, session := range *apiSessions {
 		s, err := utils.MapToDomain(&session)
@@ -67,9 +187,55 @@ func (w *WeekendService) Weekend(ctx context.Context, country_name, year string)
 	}
 
 	if len(sessions) == 0 {
-		return WeekendResponse{}, nil
+		return nil, nil
+	}
+	w.nameMeetings(ctx, sessions, country_name, year)
+
+	if err := w.cache.Set(cacheKey, sessions, w.ttl.For(w.now(), sessions...)); err != nil {
+		log.Printf("cache: %v", err)
+	}
+	return sessions, nil
+}
+
+// nameMeetings fills in each session's meeting name. Failing to look them
+// up only leaves the names empty.
+func (w *WeekendService) nameMeetings(ctx context.Context, sessions []domain.Session, country_name, year string) {
+	provider, ok := w.openf1Client.(MeetingProvider)
+	if !ok {
+		return
+	}
+
+	meetings, err := provider.GetWeekendMeetings(ctx, country_name, year)
+	if err != nil {
+		log.Printf("error looking up meetings: %v", err)
+		return
+	}
+
+	names := make(map[int]string, len(meetings))
+	for _, m := range meetings {
+		names[m.MeetingKey] = m.MeetingName
+	}
+	for i := range sessions {
+		sessions[i].MeetingName = names[sessions[i].MeetingKey]
+	}
+}
+
+// cached answers from the cache alone, for offline mode.
+func (w *WeekendService) cached(cacheKey string) (WeekendResponse, error) {
+	var cachedSessions []domain.Session
+
+	meta, found, err := cache.GetMeta(w.cache, cacheKey, &cachedSessions)
+	if err != nil {
+		log.Printf("cache: %v", err)
+	}
+	if !found || len(cachedSessions) == 0 {
+		return WeekendResponse{}, fmt.Errorf("%w: %s is not cached", openf1.ErrOffline, cacheKey)
 	}
 
-	_ = w.cache.Set(cacheKey, sessions, 24*time.Hour)
-	return WeekendResponse{Sessions: &sessions}, nil
+	return WeekendResponse{
+		Meetings: w.group(cachedSessions),
+		Warning:  utils.OfflineWarning(meta.CreatedAt, w.now()),
+		Offline:  true,
+		CachedAt: meta.CreatedAt,
+	}, nil
 }
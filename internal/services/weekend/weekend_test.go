@@ -3,17 +3,21 @@ package weekend
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bhopalg/pitwall/domain"
 	"github.com/bhopalg/pitwall/internal/cache"
 	"github.com/bhopalg/pitwall/internal/openf1"
+	"github.com/bhopalg/pitwall/internal/openf1/replay"
 )
 
 // MockCache implements the cache.Cache interface for testing
 type MockCache struct {
 	storage map[string]interface{}
+	ttls    map[string]time.Duration
 	isStale bool
 	found   bool
 }
@@ -32,6 +36,9 @@ func (m *MockCache) Get(key string, target interface{}) (bool, bool, error) {
 
 func (m *MockCache) Set(key string, value interface{}, ttl time.Duration) error {
 	m.storage[key] = value
+	if m.ttls != nil {
+		m.ttls[key] = ttl
+	}
 	return nil
 }
 
@@ -88,6 +95,17 @@ func TestWeekendService_Weekend(t *testing.T) {
 			expectedLen:    1,
 			expectRepoCall: true,
 		},
+		{
+			name: "Upcoming session without end time is kept",
+			mockResp: []openf1.Session{
+				{SessionName: "Practice 1", DateStart: "2099-07-28T11:30:00+00:00", DateEnd: "2099-07-28T12:30:00+00:00"},
+				{SessionName: "Race", DateStart: "2099-07-30T13:00:00+00:00", DateEnd: ""},
+			},
+			cacheFound:     false,
+			expectedError:  false,
+			expectedLen:    2,
+			expectRepoCall: true,
+		},
 		{
 			name:            "Stale Cache + Repo Failure (Fallback)",
 			mockErr:         errors.New("api down"),
@@ -145,8 +163,253 @@ func TestWeekendService_Weekend(t *testing.T) {
 			}
 
 			if !tc.expectedError && tc.expectedLen > 0 {
-				if resp.Sessions == nil || len(*resp.Sessions) != tc.expectedLen {
-					t.Errorf("expected %d sessions, got %v", tc.expectedLen, resp.Sessions)
+				if sessions := Sessions(resp.Meetings); len(sessions) != tc.expectedLen {
+					t.Errorf("expected %d sessions, got %v", tc.expectedLen, sessions)
+				}
+			}
+		})
+	}
+}
+
+func TestWeekendService_Fixtures(t *testing.T) {
+	client := openf1.New(openf1.WithTransport(replay.New("testdata/fixtures", replay.Replay)))
+	mockCache := &MockCache{storage: make(map[string]interface{})}
+
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := New(client, mockCache, WithZone(func(*domain.Session) *time.Location { return auckland }))
+	resp, err := service.Weekend(context.Background(), "Belgium", "2023")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Meetings) != 1 || resp.Meetings[0].Name != "Belgian Grand Prix" || resp.Meetings[0].Format != FormatSprint {
+		t.Fatalf("expected the Belgian Grand Prix sprint weekend, got %+v", resp.Meetings)
+	}
+	// Days are worked out in the service's zone, where the race ends up on
+	// Monday.
+	days := resp.Meetings[0].Days
+	if last := days[len(days)-1].Date; last.Weekday() != time.Monday || last.Location() != auckland {
+		t.Errorf("expected the last day to be Monday in Auckland, got %v", last)
+	}
+
+	sessions := Sessions(resp.Meetings)
+	if len(sessions) != 5 {
+		t.Fatalf("expected 5 sessions, got %v", sessions)
+	}
+
+	race := sessions[4]
+	if race.SessionName != "Race" || race.SessionKey != 9141 || race.CircuitName != "Spa-Francorchamps" {
+		t.Errorf("unexpected race session: %+v", race)
+	}
+	if race.MeetingName != "Belgian Grand Prix" {
+		t.Errorf("expected the meeting name to be looked up, got %q", race.MeetingName)
+	}
+
+	wantStart := time.Date(2023, 7, 30, 13, 0, 0, 0, time.UTC)
+	if !race.DateStart.Equal(wantStart) {
+		t.Errorf("expected race to start at %v, got %v", wantStart, race.DateStart)
+	}
+
+	if race.State(wantStart.Add(24*time.Hour)) != domain.StateFinished {
+		t.Errorf("expected race to be finished a day later")
+	}
+}
+
+func TestWeekendService_TTL(t *testing.T) {
+	weekend := []openf1.Session{
+		{SessionName: "Practice 1", DateStart: "2023-07-28T11:30:00Z", DateEnd: "2023-07-28T12:30:00Z"},
+		{SessionName: "Race", DateStart: "2023-07-30T13:00:00Z", DateEnd: "2023-07-30T15:00:00Z"},
+	}
+	policy := cache.DefaultTTLPolicy()
+
+	testcases := []struct {
+		name string
+		now  time.Time
+		want time.Duration
+	}{
+		{name: "Finished weekend", now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), want: policy.Finished},
+		{name: "Race still to come", now: time.Date(2023, 7, 30, 12, 0, 0, 0, time.UTC), want: time.Hour},
+		{name: "Race live", now: time.Date(2023, 7, 30, 14, 0, 0, 0, time.UTC), want: policy.Live},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCache := &MockCache{
+				storage: make(map[string]interface{}),
+				ttls:    make(map[string]time.Duration),
+			}
+
+			service := New(&MockOpenF1{sessions: &weekend}, mockCache, WithClock(func() time.Time { return tc.now }))
+			if _, err := service.Weekend(context.Background(), "Belgium", "2023"); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := mockCache.ttls["weekend:Belgium:2023"]; got != tc.want {
+				t.Errorf("expected ttl %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWeekendService_Offline(t *testing.T) {
+	testcases := []struct {
+		name          string
+		cacheFound    bool
+		expectedError error
+	}{
+		{
+			name:       "Stale cache is served",
+			cacheFound: true,
+		},
+		{
+			name:          "Nothing cached",
+			expectedError: openf1.ErrOffline,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCache := &MockCache{
+				storage: map[string]interface{}{
+					"weekend:Belgium:2023": &[]domain.Session{{SessionName: "Race"}},
+				},
+				found:   tc.cacheFound,
+				isStale: true,
+			}
+			mockAPI := &MockOpenF1{err: errors.New("network used")}
+
+			res, err := New(mockAPI, mockCache, WithOffline()).Weekend(context.Background(), "Belgium", "2023")
+
+			if mockAPI.called {
+				t.Error("expected the API not to be called while offline")
+			}
+			if tc.expectedError != nil {
+				if !errors.Is(err, tc.expectedError) {
+					t.Errorf("expected %v, got %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !res.Offline || len(Sessions(res.Meetings)) != 1 {
+				t.Errorf("expected offline cached sessions, got %+v", res)
+			}
+			if res.Warning != "📴 Offline: showing cached data." {
+				t.Errorf("unexpected warning %q", res.Warning)
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	session := func(meetingKey int, name, start string) domain.Session {
+		dateStart, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return domain.Session{MeetingKey: meetingKey, SessionName: name, DateStart: dateStart}
+	}
+	utc := func(*domain.Session) *time.Location { return time.UTC }
+	vegas, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name           string
+		sessions       []domain.Session
+		zone           func(*domain.Session) *time.Location
+		expectedFormat []Format
+		// expectedDays lists each meeting's days as "Mon 02 Jan: Session, ...".
+		expectedDays [][]string
+	}{
+		{
+			name: "Conventional weekend out of order",
+			sessions: []domain.Session{
+				session(1, "Race", "2024-05-26T13:00:00Z"),
+				session(1, "Practice 1", "2024-05-24T11:30:00Z"),
+				session(1, "Practice 3", "2024-05-25T10:30:00Z"),
+				session(1, "Practice 2", "2024-05-24T15:00:00Z"),
+				session(1, "Qualifying", "2024-05-25T14:00:00Z"),
+			},
+			zone:           utc,
+			expectedFormat: []Format{FormatConventional},
+			expectedDays: [][]string{{
+				"Fri 24 May: Practice 1, Practice 2",
+				"Sat 25 May: Practice 3, Qualifying",
+				"Sun 26 May: Race",
+			}},
+		},
+		{
+			name: "Thursday sessions and a sprint",
+			sessions: []domain.Session{
+				session(1, "Practice 1", "2024-03-07T13:30:00Z"),
+				session(1, "Sprint Qualifying", "2024-03-08T10:30:00Z"),
+				session(1, "Sprint", "2024-03-09T10:00:00Z"),
+			},
+			zone:           utc,
+			expectedFormat: []Format{FormatSprint},
+			expectedDays: [][]string{{
+				"Thu 07 Mar: Practice 1",
+				"Fri 08 Mar: Sprint Qualifying",
+				"Sat 09 Mar: Sprint",
+			}},
+		},
+		{
+			name: "Late race in the viewer's zone",
+			sessions: []domain.Session{
+				session(1, "Qualifying", "2023-11-18T08:00:00Z"),
+				session(1, "Race", "2023-11-19T06:00:00Z"),
+			},
+			zone:           func(*domain.Session) *time.Location { return vegas },
+			expectedFormat: []Format{FormatConventional},
+			expectedDays: [][]string{{
+				"Sat 18 Nov: Qualifying, Race",
+			}},
+		},
+		{
+			name: "Testing before the grand prix",
+			sessions: []domain.Session{
+				session(2, "Race", "2024-03-02T15:00:00Z"),
+				session(1, "Day 2", "2024-02-22T07:00:00Z"),
+				session(1, "Day 1", "2024-02-21T07:00:00Z"),
+			},
+			zone:           utc,
+			expectedFormat: []Format{FormatTesting, FormatConventional},
+			expectedDays: [][]string{
+				{"Wed 21 Feb: Day 1", "Thu 22 Feb: Day 2"},
+				{"Sat 02 Mar: Race"},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			meetings := Group(tc.sessions, tc.zone)
+
+			if len(meetings) != len(tc.expectedDays) {
+				t.Fatalf("expected %d meetings, got %+v", len(tc.expectedDays), meetings)
+			}
+			for i, m := range meetings {
+				if m.Format != tc.expectedFormat[i] {
+					t.Errorf("meeting %d: expected format %s, got %s", i, tc.expectedFormat[i], m.Format)
+				}
+
+				var days []string
+				for _, day := range m.Days {
+					var names []string
+					for _, s := range day.Sessions {
+						names = append(names, s.SessionName)
+					}
+					days = append(days, day.Date.Format("Mon 02 Jan")+": "+strings.Join(names, ", "))
+				}
+				if !slices.Equal(days, tc.expectedDays[i]) {
+					t.Errorf("meeting %d: expected days %q, got %q", i, tc.expectedDays[i], days)
 				}
 			}
 		})
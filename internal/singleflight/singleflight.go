@@ -0,0 +1,103 @@
+// Package singleflight suppresses duplicate concurrent calls, so many
+// requests for the same cache key result in a single API call.
+package singleflight
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+type call struct {
+	done chan struct{}
+	val  any
+	err  error
+	// panicked holds the value fn panicked with, if it did.
+	panicked any
+	// dups counts callers that joined an in-flight call.
+	dups int
+	// waiting counts callers still waiting for the result. The call is
+	// cancelled once all of them have given up.
+	waiting int
+	cancel  context.CancelFunc
+}
+
+// Group runs at most one call per key at a time. The zero value is ready to
+// use.
+type Group struct {
+	mu sync.Mutex
+	m  map[string]*call
+}
+
+// Do runs fn for key, or, if a call for key is already running, waits for it
+// and returns its result. shared reports whether the result was given to
+// more than one caller.
+//
+// fn gets a context that keeps ctx's values but is only cancelled once every
+// caller waiting on it has given up, so one caller timing out does not fail
+// the others. A caller whose ctx is done stops waiting and gets ctx.Err().
+//
+// If fn panics, waiting callers get an error and the caller that started the
+// call panics with the same value.
+func (g *Group) Do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (v any, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	c, joined := g.m[key]
+	if joined {
+		c.dups++
+		c.waiting++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call{done: make(chan struct{}), waiting: 1, cancel: cancel}
+		g.m[key] = c
+		go g.run(callCtx, key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiting--
+		if c.waiting == 0 {
+			c.cancel()
+			if g.m[key] == c {
+				delete(g.m, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err(), false
+	}
+
+	if c.panicked != nil && !joined {
+		panic(c.panicked)
+	}
+
+	g.mu.Lock()
+	shared = c.dups > 0
+	g.mu.Unlock()
+	return c.val, c.err, shared
+}
+
+func (g *Group) run(ctx context.Context, key string, c *call, fn func(ctx context.Context) (any, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.val = nil
+			c.panicked = r
+			c.err = fmt.Errorf("singleflight: call for %s panicked: %v\n%s", key, r, debug.Stack())
+		}
+		c.cancel()
+
+		g.mu.Lock()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn(ctx)
+}
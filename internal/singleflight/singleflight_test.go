@@ -0,0 +1,159 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	t.Run("Concurrent calls share one execution", func(t *testing.T) {
+		var g Group
+		var calls atomic.Int32
+		release := make(chan struct{})
+
+		var wg sync.WaitGroup
+		results := make([]any, 10)
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, _, _ := g.Do(context.Background(), "latest", func(context.Context) (any, error) {
+					calls.Add(1)
+					<-release
+					return "Race", nil
+				})
+				results[i] = v
+			}()
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if calls.Load() != 1 {
+			t.Errorf("expected 1 call, got %d", calls.Load())
+		}
+		for i, v := range results {
+			if v != "Race" {
+				t.Errorf("caller %d got %v", i, v)
+			}
+		}
+	})
+
+	t.Run("Errors are shared and not remembered", func(t *testing.T) {
+		var g Group
+		ctx := context.Background()
+		boom := errors.New("boom")
+
+		if _, err, _ := g.Do(ctx, "k", func(context.Context) (any, error) { return nil, boom }); !errors.Is(err, boom) {
+			t.Fatalf("expected boom, got %v", err)
+		}
+
+		v, err, shared := g.Do(ctx, "k", func(context.Context) (any, error) { return 1, nil })
+		if err != nil || v != 1 || shared {
+			t.Errorf("expected a fresh call, got v=%v err=%v shared=%v", v, err, shared)
+		}
+	})
+
+	t.Run("Panics fail waiting callers and re-panic in the leader", func(t *testing.T) {
+		var g Group
+		ctx := context.Background()
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		leader := make(chan any, 1)
+		go func() {
+			defer func() { leader <- recover() }()
+			g.Do(ctx, "k", func(context.Context) (any, error) {
+				close(started)
+				<-release
+				panic("boom")
+			})
+		}()
+
+		<-started
+		waiter := make(chan error, 1)
+		go func() {
+			v, err, _ := g.Do(ctx, "k", func(context.Context) (any, error) { return "fresh", nil })
+			if v != nil {
+				t.Errorf("expected no value from a panicked call, got %v", v)
+			}
+			waiter <- err
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		if r := <-leader; r != "boom" {
+			t.Errorf("expected the leader to panic with boom, got %v", r)
+		}
+		if err := <-waiter; err == nil {
+			t.Error("expected the waiting caller to get an error")
+		}
+	})
+
+	t.Run("Cancelling the leader does not fail waiting callers", func(t *testing.T) {
+		var g Group
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		leaderCtx, cancel := context.WithCancel(context.Background())
+		leader := make(chan error, 1)
+		go func() {
+			_, err, _ := g.Do(leaderCtx, "k", func(ctx context.Context) (any, error) {
+				close(started)
+				select {
+				case <-release:
+					return "Race", nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			})
+			leader <- err
+		}()
+
+		<-started
+		waiter := make(chan any, 1)
+		go func() {
+			v, _, _ := g.Do(context.Background(), "k", func(context.Context) (any, error) { return "fresh", nil })
+			waiter <- v
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		if err := <-leader; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the leader to stop waiting, got %v", err)
+		}
+
+		close(release)
+		if v := <-waiter; v != "Race" {
+			t.Errorf("expected the waiting caller to get the shared result, got %v", v)
+		}
+	})
+
+	t.Run("Call is cancelled once every caller gives up", func(t *testing.T) {
+		var g Group
+		cancelled := make(chan struct{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err, _ := g.Do(ctx, "k", func(ctx context.Context) (any, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the deadline error, got %v", err)
+		}
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("expected the abandoned call to be cancelled")
+		}
+	})
+}
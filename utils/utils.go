@@ -1,55 +1,174 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bhopalg/pitwall/domain"
 	"github.com/bhopalg/pitwall/internal/openf1"
 )
 
+// dateLayouts are the timestamp formats OpenF1 emits. Fractional seconds
+// are optional in every layout; timestamps without an offset are UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// ParseDate parses an OpenF1 timestamp and returns it in UTC.
 func ParseDate(date string) (*time.Time, error) {
-	pd, err := time.Parse(time.RFC3339, date)
+	var firstErr error
+	for _, layout := range dateLayouts {
+		pd, err := time.Parse(layout, date)
+		if err == nil {
+			pd = pd.UTC()
+			return &pd, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, firstErr
+}
+
+// ParseOptionalDate is ParseDate for fields OpenF1 may send as null or
+// leave empty, such as the end of an upcoming session. Those return the zero
+// time.
+func ParseOptionalDate(date string) (time.Time, error) {
+	if date == "" || date == "null" {
+		return time.Time{}, nil
+	}
+
+	pd, err := ParseDate(date)
 	if err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
+	return *pd, nil
+}
 
-	return &pd, nil
+// MappingError reports which field of which session could not be mapped.
+type MappingError struct {
+	SessionKey int
+	Field      string
+	Value      string
+	Err        error
 }
 
-func PrintSessionStatus(s *domain.Session, now time.Time) {
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("session %d: invalid %s %q: %v", e.SessionKey, e.Field, e.Value, e.Err)
+}
+
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// ParseGMTOffset parses a meeting's OpenF1 gmt_offset, e.g. "02:00:00" or
+// "-04:00:00".
+func ParseGMTOffset(offset string) (time.Duration, error) {
+	sign := time.Duration(1)
+	rest := offset
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		sign, rest = -1, rest[1:]
+	case strings.HasPrefix(rest, "+"):
+		rest = rest[1:]
+	}
+
+	parts := strings.Split(rest, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid GMT offset %q", offset)
+	}
+
+	var d time.Duration
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (i > 0 && n > 59) {
+			return 0, fmt.Errorf("invalid GMT offset %q", offset)
+		}
+		d += time.Duration(n) * units[i]
+	}
+	if d > 14*time.Hour {
+		return 0, fmt.Errorf("invalid GMT offset %q", offset)
+	}
+	return sign * d, nil
+}
+
+// TrackZone returns the time zone of the session's circuit, named after its
+// offset like "UTC+2", or nil if OpenF1 gave no offset.
+func TrackZone(s *domain.Session) *time.Location {
+	if s.GMTOffset == nil {
+		return nil
+	}
+
+	offset := *s.GMTOffset
+	name := "UTC"
+	if offset != 0 {
+		sign := "+"
+		if offset < 0 {
+			sign, offset = "-", -offset
+		}
+		name += sign + strconv.Itoa(int(offset/time.Hour))
+		if m := int(offset % time.Hour / time.Minute); m != 0 {
+			name += fmt.Sprintf(":%02d", m)
+		}
+	}
+	return time.FixedZone(name, int(*s.GMTOffset/time.Second))
+}
+
+// SessionZone returns the zone to show a session's times in: the
+// circuit's own when trackTime is set and its offset is known, otherwise
+// loc.
+func SessionZone(s *domain.Session, loc *time.Location, trackTime bool) *time.Location {
+	if trackTime {
+		if zone := TrackZone(s); zone != nil {
+			return zone
+		}
+	}
+	return loc
+}
+
+// PrintSessionStatus prints the session's state and countdown, with times
+// in loc labelled by zone abbreviation.
+func PrintSessionStatus(s *domain.Session, now time.Time, loc *time.Location) {
 	state := s.State(now)
-	loc, _ := time.LoadLocation("Europe/London")
 
 	fmt.Printf("Status: %s\n", state)
 
 	switch state {
 	case domain.StateFuture:
-		startTimeUK := s.DateStart.In(loc)
-		startsAtStr := startTimeUK.Format("Mon 02 Jan 2006, 15:04")
+		startsAtStr := s.DateStart.In(loc).Format("Mon 02 Jan 2006, 15:04 MST")
 		diff := s.DateStart.Sub(now).Round(time.Minute)
-		fmt.Printf("Starts at: %s (UK)\n", startsAtStr)
-		fmt.Printf("Starts in: %s\n", formatDuration(diff))
+		fmt.Printf("Starts at: %s\n", startsAtStr)
+		fmt.Printf("Starts in: %s\n", FormatDuration(diff))
 
 	case domain.StateLive:
 		if !s.DateEnd.IsZero() {
-			endTime := s.DateEnd.In(loc).Format("15:04")
+			endTime := s.DateEnd.In(loc).Format("15:04 MST")
 			diff := s.DateEnd.Sub(now).Round(time.Minute)
-			fmt.Printf("Ends at: %s (UK)\n", endTime)
-			fmt.Printf("Ends in: %s\n", formatDuration(diff))
+			fmt.Printf("Ends at: %s\n", endTime)
+			fmt.Printf("Ends in: %s\n", FormatDuration(diff))
 		} else {
 			fmt.Println("Ends at: TBD")
 		}
 
 	case domain.StateFinished:
-		endTime := s.DateEnd.In(loc).Format("15:04")
+		endTime := s.DateEnd.In(loc).Format("15:04 MST")
 		diff := now.Sub(s.DateEnd).Round(time.Minute)
-		fmt.Printf("Ended at: %s (UK)\n", endTime)
-		fmt.Printf("Ended: %s ago\n", formatDuration(diff))
+		fmt.Printf("Ended at: %s\n", endTime)
+		fmt.Printf("Ended: %s ago\n", FormatDuration(diff))
 	}
 }
 
-func formatDuration(d time.Duration) string {
+// FormatDuration formats d as "1d 2h 3m", or "2h 3m" under a day.
+func FormatDuration(d time.Duration) string {
 	days := int(d.Hours()) / 24
 	h := int(d.Hours()) % 24
 	m := int(d.Minutes()) % 60
@@ -60,27 +179,50 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dh %dm", h, m)
 }
 
+// OfflineWarning tells the user that data comes from the cache because
+// pitwall is offline, and how old it is.
+func OfflineWarning(cachedAt, now time.Time) string {
+	if cachedAt.IsZero() {
+		return "📴 Offline: showing cached data."
+	}
+	return fmt.Sprintf("📴 Offline: showing data cached %s ago.", FormatDuration(max(now.Sub(cachedAt), 0)))
+}
+
 func MapToDomain(apiSession *openf1.Session) (*domain.Session, error) {
+	if apiSession == nil {
+		return nil, errors.New("map session: nil session")
+	}
+
 	date_start, err := ParseDate(apiSession.DateStart)
 	if err != nil {
-		return nil, err
+		return nil, &MappingError{SessionKey: apiSession.SessionKey, Field: "date_start", Value: apiSession.DateStart, Err: err}
 	}
 
-	date_end, err := ParseDate(apiSession.DateEnd)
+	date_end, err := ParseOptionalDate(apiSession.DateEnd)
 	if err != nil {
-		return nil, err
+		return nil, &MappingError{SessionKey: apiSession.SessionKey, Field: "date_end", Value: apiSession.DateEnd, Err: err}
+	}
+
+	var gmt_offset *time.Duration
+	if apiSession.GMTOffset != "" {
+		offset, err := ParseGMTOffset(apiSession.GMTOffset)
+		if err != nil {
+			return nil, &MappingError{SessionKey: apiSession.SessionKey, Field: "gmt_offset", Value: apiSession.GMTOffset, Err: err}
+		}
+		gmt_offset = &offset
 	}
 
 	mappedSession := &domain.Session{
 		SessionKey:  apiSession.SessionKey,
 		SessionName: apiSession.SessionName,
 		DateStart:   *date_start,
-		DateEnd:     *date_end,
+		DateEnd:     date_end,
 		Location:    apiSession.Location,
 		CountryName: apiSession.CountryName,
 		CircuitName: apiSession.CircuitName,
 		MeetingKey:  apiSession.MeetingKey,
 		Year:        apiSession.Year,
+		GMTOffset:   gmt_offset,
 	}
 
 	now := time.Now().UTC()
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"strings"
@@ -28,6 +29,26 @@ func TestParseDate(t *testing.T) {
 			input:   "2023-11-26T13:00:00+01:00",
 			wantErr: false,
 		},
+		{
+			name:    "Fractional seconds with offset",
+			input:   "2023-09-16T13:03:35.292000+00:00",
+			wantErr: false,
+		},
+		{
+			name:    "Offset without colon",
+			input:   "2023-11-26T13:00:00+0000",
+			wantErr: false,
+		},
+		{
+			name:    "No offset",
+			input:   "2023-11-26T13:00:00",
+			wantErr: false,
+		},
+		{
+			name:    "Space separator",
+			input:   "2023-11-26 13:00:00.5+00:00",
+			wantErr: false,
+		},
 		{
 			name:    "Invalid Date Format",
 			input:   "26-11-2023 13:00",
@@ -59,6 +80,38 @@ func TestParseDate(t *testing.T) {
 			if !tt.wantErr && got == nil {
 				t.Error("ParseDate() returned nil for a valid input")
 			}
+
+			if !tt.wantErr && got.Location() != time.UTC {
+				t.Errorf("ParseDate() returned %v, want UTC", got.Location())
+			}
+		})
+	}
+}
+
+func TestParseOptionalDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantZero bool
+		wantErr  bool
+	}{
+		{name: "Empty string", input: "", wantZero: true},
+		{name: "Null literal", input: "null", wantZero: true},
+		{name: "Valid date", input: "2023-07-30T15:00:00+00:00"},
+		{name: "Invalid date", input: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOptionalDate(tt.input)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOptionalDate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got.IsZero() != tt.wantZero {
+				t.Errorf("ParseOptionalDate() = %v, wantZero %v", got, tt.wantZero)
+			}
 		})
 	}
 }
@@ -67,10 +120,16 @@ func TestPrintSessionStatus(t *testing.T) {
 	start := time.Date(2023, 7, 29, 14, 0, 0, 0, time.UTC)
 	end := time.Date(2023, 7, 29, 16, 0, 0, 0, time.UTC)
 
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		name           string
 		session        *domain.Session
 		now            time.Time
+		loc            *time.Location
 		expectedOutput []string
 	}{
 		{
@@ -80,8 +139,10 @@ func TestPrintSessionStatus(t *testing.T) {
 				DateEnd:   end,
 			},
 			now: start.Add(-52 * time.Hour).Add(-30 * time.Minute),
+			loc: time.UTC,
 			expectedOutput: []string{
 				"Status: Future",
+				"Starts at: Sat 29 Jul 2023, 14:00 UTC",
 				"Starts in: 2d 4h 30m",
 			},
 		},
@@ -92,8 +153,10 @@ func TestPrintSessionStatus(t *testing.T) {
 				DateEnd:   end,
 			},
 			now: start.Add(-2 * time.Hour).Add(-14 * time.Minute),
+			loc: tokyo,
 			expectedOutput: []string{
 				"Status: Future",
+				"Starts at: Sat 29 Jul 2023, 23:00 JST",
 				"Starts in: 2h 14m",
 			},
 		},
@@ -104,8 +167,10 @@ func TestPrintSessionStatus(t *testing.T) {
 				DateEnd:   end,
 			},
 			now: start.Add(1 * time.Hour).Add(30 * time.Minute),
+			loc: time.FixedZone("UTC+2", 2*60*60),
 			expectedOutput: []string{
 				"Status: Live",
+				"Ends at: 18:00 UTC+2",
 				"Ends in: 0h 30m",
 			},
 		},
@@ -117,6 +182,7 @@ func TestPrintSessionStatus(t *testing.T) {
 			},
 			// 1 day and 2 hours after end
 			now: end.Add(26 * time.Hour),
+			loc: time.UTC,
 			expectedOutput: []string{
 				"Status: Finished",
 				"Ended: 1d 2h 0m ago",
@@ -130,7 +196,7 @@ func TestPrintSessionStatus(t *testing.T) {
 			r, w, _ := os.Pipe()
 			os.Stdout = w
 
-			PrintSessionStatus(tt.session, tt.now)
+			PrintSessionStatus(tt.session, tt.now, tt.loc)
 
 			w.Close()
 			os.Stdout = oldStdout
@@ -149,11 +215,13 @@ func TestPrintSessionStatus(t *testing.T) {
 
 func TestMapToDomain(t *testing.T) {
 	testcases := []struct {
-		name          string
-		input         *openf1.Session
-		expectedError bool
-		expectedName  string
-		expectedState domain.SessionState
+		name           string
+		input          *openf1.Session
+		expectedError  bool
+		expectedName   string
+		expectedField  string
+		expectedState  domain.SessionState
+		expectedOffset string
 	}{
 		{
 			name: "Successful mapping with all fields",
@@ -167,6 +235,18 @@ func TestMapToDomain(t *testing.T) {
 				CircuitName: "Spa-Francorchamps",
 				MeetingKey:  1216,
 				Year:        2023,
+				GMTOffset:   "02:00:00",
+			},
+			expectedError:  false,
+			expectedName:   "Race",
+			expectedOffset: "UTC+2",
+		},
+		{
+			name: "Upcoming session with unknown end",
+			input: &openf1.Session{
+				SessionKey:  9999,
+				SessionName: "Race",
+				DateStart:   "2099-07-30T13:00:00+00:00",
 			},
 			expectedError: false,
 			expectedName:  "Race",
@@ -174,18 +254,32 @@ func TestMapToDomain(t *testing.T) {
 		{
 			name: "Fail on invalid start date",
 			input: &openf1.Session{
-				DateStart: "invalid-date",
-				DateEnd:   "2023-07-30T15:00:00Z",
+				SessionKey: 9141,
+				DateStart:  "invalid-date",
+				DateEnd:    "2023-07-30T15:00:00Z",
+			},
+			expectedError: true,
+			expectedField: "date_start",
+		},
+		{
+			name: "Fail on invalid GMT offset",
+			input: &openf1.Session{
+				SessionKey: 9141,
+				DateStart:  "2023-07-30T13:00:00Z",
+				GMTOffset:  "two hours",
 			},
 			expectedError: true,
+			expectedField: "gmt_offset",
 		},
 		{
 			name: "Fail on invalid end date",
 			input: &openf1.Session{
-				DateStart: "2023-07-30T13:00:00Z",
-				DateEnd:   "broken",
+				SessionKey: 9141,
+				DateStart:  "2023-07-30T13:00:00Z",
+				DateEnd:    "broken",
 			},
 			expectedError: true,
+			expectedField: "date_end",
 		},
 	}
 
@@ -197,6 +291,16 @@ func TestMapToDomain(t *testing.T) {
 				t.Fatalf("MapToDomain() error = %v, expectedError %v", err, tc.expectedError)
 			}
 
+			if tc.expectedField != "" {
+				var mErr *MappingError
+				if !errors.As(err, &mErr) {
+					t.Fatalf("expected a *MappingError, got %T", err)
+				}
+				if mErr.Field != tc.expectedField || mErr.SessionKey != tc.input.SessionKey {
+					t.Errorf("expected error for %s of session %d, got %v", tc.expectedField, tc.input.SessionKey, mErr)
+				}
+			}
+
 			if !tc.expectedError {
 				if got.SessionName != tc.expectedName {
 					t.Errorf("Expected name %s, got %s", tc.expectedName, got.SessionName)
@@ -213,6 +317,72 @@ func TestMapToDomain(t *testing.T) {
 				if got.SessionState == "" {
 					t.Error("Expected SessionState to be populated, but it was empty")
 				}
+
+				zone := TrackZone(got)
+				if (zone == nil) != (tc.expectedOffset == "") {
+					t.Fatalf("Expected track zone %q, got %v", tc.expectedOffset, zone)
+				}
+				if zone != nil && zone.String() != tc.expectedOffset {
+					t.Errorf("Expected track zone %q, got %q", tc.expectedOffset, zone)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGMTOffset(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{name: "Europe summer", input: "02:00:00", expected: 2 * time.Hour},
+		{name: "Americas", input: "-04:00:00", expected: -4 * time.Hour},
+		{name: "Half hour", input: "05:30:00", expected: 5*time.Hour + 30*time.Minute},
+		{name: "UTC", input: "00:00:00", expected: 0},
+		{name: "Without seconds", input: "+03:00", expected: 3 * time.Hour},
+		{name: "Not an offset", input: "CEST", wantErr: true},
+		{name: "Too far", input: "25:00:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGMTOffset(tt.input)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGMTOffset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseGMTOffset() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSessionZone(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := -4*time.Hour - 30*time.Minute
+
+	tests := []struct {
+		name      string
+		session   *domain.Session
+		trackTime bool
+		expected  string
+	}{
+		{name: "Chosen zone", session: &domain.Session{GMTOffset: &offset}, expected: "Europe/London"},
+		{name: "Track time", session: &domain.Session{GMTOffset: &offset}, trackTime: true, expected: "UTC-4:30"},
+		{name: "Track time with unknown offset", session: &domain.Session{}, trackTime: true, expected: "Europe/London"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SessionZone(tt.session, london, tt.trackTime)
+			if got.String() != tt.expected {
+				t.Errorf("SessionZone() = %q, expected %q", got, tt.expected)
 			}
 		})
 	}